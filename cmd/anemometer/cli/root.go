@@ -12,11 +12,37 @@ var (
 		Use:   "anemometer",
 		Short: "Anemometer (A SQL -> StatsD metrics generator)",
 	}
+
+	configPath string
+	configDir  string
 )
 
 func init() {
 }
 
+// Adds the flags used to locate the config to a command
+func addConfigFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(
+		&configPath,
+		"config",
+		"c",
+		"/etc/anemometer.yml",
+		"the full path to the yaml config file, default: /etc/anemometer.yml")
+	cmd.Flags().StringVar(
+		&configDir,
+		"config-dir",
+		"",
+		"a directory of yaml/json files containing additional monitor definitions")
+}
+
+// When only a config directory is given, don't require the default config
+// file to exist as well
+func resolveConfigFlags(cmd *cobra.Command) {
+	if configDir != "" && !cmd.Flags().Changed("config") {
+		configPath = ""
+	}
+}
+
 // Execute the root command
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
@@ -2,32 +2,31 @@ package cli
 
 import (
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/simplifi/anemometer/pkg/anemometer/config"
 	"github.com/simplifi/anemometer/pkg/anemometer/monitor"
+	"github.com/simplifi/anemometer/pkg/anemometer/sink"
 	"github.com/spf13/cobra"
 )
 
 var (
-	configPath string
-	debug      bool
+	debug bool
 )
 
 var startCmd = &cobra.Command{
 	Use:   "start",
 	Short: "Start the Anemometer agent",
 	Run: func(cmd *cobra.Command, args []string) {
+		resolveConfigFlags(cmd)
 		start()
 	},
 }
 
 func init() {
-	startCmd.Flags().StringVarP(
-		&configPath,
-		"config",
-		"c",
-		"/etc/anemometer.yml",
-		"the full path to the yaml config file, default: /etc/anemometer.yml")
+	addConfigFlags(startCmd)
 	startCmd.Flags().BoolVarP(
 		&debug,
 		"debug",
@@ -39,23 +38,34 @@ func init() {
 
 // Starts up the agent
 func start() {
-	var exit = make(chan bool)
+	var exit = make(chan os.Signal, 1)
+	signal.Notify(exit, syscall.SIGINT, syscall.SIGTERM)
 
 	log.Printf("INFO: Starting Anemometer")
 
-	cfg, err := config.Read(configPath)
+	cfg, err := config.Load(configPath, configDir)
 	if err != nil {
 		log.Panicf("ERROR: Failed to load config: %v", err)
 	}
 
+	metricSink, err := sink.New(cfg)
+	if err != nil {
+		log.Panicf("ERROR: Failed to create sink: %v", err)
+	}
+
 	for _, mtConfig := range cfg.Monitors {
-		mt, err := monitor.New(cfg.StatsdConfig, mtConfig)
+		mt, err := monitor.New(mtConfig, metricSink)
 		log.Printf("INFO: Launching monitor '%v'", mtConfig.Name)
 		if err != nil {
 			log.Panicf("ERROR: Failed to start monitor '%v': %v", mtConfig.Name, err)
 		}
 		go mt.Start(debug)
 	}
-	// Block until something kills the process
-	<-exit
+	// Block until something kills the process, then flush anything the sinks
+	// still have buffered
+	sig := <-exit
+	log.Printf("INFO: Received %v, shutting down", sig)
+	if err := metricSink.Close(); err != nil {
+		log.Printf("ERROR: Failed to close sink: %v", err)
+	}
 }
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/simplifi/anemometer/pkg/anemometer/config"
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v3"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate the config and print the fully resolved monitors",
+	Run: func(cmd *cobra.Command, args []string) {
+		resolveConfigFlags(cmd)
+		if err := validate(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	addConfigFlags(validateCmd)
+	rootCmd.AddCommand(validateCmd)
+}
+
+// Loads the config and prints every monitor with its defaults and profile
+// applied, in the same form it would be written in the config file, with
+// database passwords redacted
+func validate() error {
+	cfg, err := config.Load(configPath, configDir)
+	if err != nil {
+		return err
+	}
+
+	for _, mtConfig := range cfg.Monitors {
+		mtConfig.DatabaseConfig = mtConfig.DatabaseConfig.Redacted()
+
+		settings := make(map[string]interface{})
+		if err := mapstructure.Decode(mtConfig, &settings); err != nil {
+			return err
+		}
+
+		fmt.Printf("# %s (from %s)\n---\n", mtConfig.Name, mtConfig.Source)
+
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(settings); err != nil {
+			return err
+		}
+		encoder.Close()
+		fmt.Println()
+	}
+
+	fmt.Printf("Config is valid: %d monitor(s)\n", len(cfg.Monitors))
+	return nil
+}
@@ -2,9 +2,17 @@ package config
 
 import (
 	"fmt"
+	"math"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"text/template"
+	"time"
 
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/spf13/viper"
 )
 
@@ -28,35 +36,323 @@ monitors:
       FROM      pg_stat_activity
       WHERE     query != '<IDLE>'
       GROUP BY  usename
+include:
+  - conf.d/*.yml
 */
 
 // Config is used to store configuration for the Monitors
 type Config struct {
-	StatsdConfig StatsdConfig    `mapstructure:"statsd"`
-	Monitors     []MonitorConfig `mapstructure:"monitors"`
+	Include      []string     `mapstructure:"include"`
+	StatsdConfig StatsdConfig `mapstructure:"statsd"`
+	// DatadogAPIConfig sends metrics and events straight to the Datadog API,
+	// for hosts without a Datadog agent
+	DatadogAPIConfig DatadogAPIConfig `mapstructure:"datadog_api"`
+	// OTLPConfig exports metrics to an OpenTelemetry collector
+	OTLPConfig OTLPConfig `mapstructure:"otlp"`
+	// InfluxDBConfig and GraphiteConfig feed legacy time series databases
+	InfluxDBConfig InfluxDBConfig `mapstructure:"influxdb"`
+	GraphiteConfig GraphiteConfig `mapstructure:"graphite"`
+	// JSONLinesConfig records everything sent as JSON lines
+	JSONLinesConfig JSONLinesConfig `mapstructure:"json_lines"`
+	// WebhookConfig posts events to an HTTP endpoint, such as Slack
+	WebhookConfig WebhookConfig `mapstructure:"webhook"`
+	// Defaults are merged into every monitor, and Profiles into the monitors
+	// that name them, unless the monitor overrides the value itself.
+	Defaults MonitorConfig            `mapstructure:"defaults"`
+	Profiles map[string]MonitorConfig `mapstructure:"profiles"`
+	Monitors []MonitorConfig          `mapstructure:"monitors"`
 }
 
 // StatsdConfig holds statsd specific configuration
 type StatsdConfig struct {
-	Address string   `mapstructure:"address"`
-	Tags    []string `mapstructure:"tags"`
+	// Enabled defaults to true, disable it when only using the other sinks
+	Enabled bool `mapstructure:"enabled"`
+	// Address is host:port or udp://host:port for UDP, or unix://,
+	// unixgram:// or unixstream:// followed by a socket path. When empty the
+	// DD_DOGSTATSD_URL and DD_AGENT_HOST environment variables are used.
+	Address   string   `mapstructure:"address"`
+	Tags      []string `mapstructure:"tags"`
+	Namespace string   `mapstructure:"namespace"`
+	// Aggregation is the client-side aggregation mode: basic (the default,
+	// gauges/counts/sets), extended (also histograms/distributions/timings)
+	// or none
+	Aggregation           string        `mapstructure:"aggregation"`
+	AggregationInterval   time.Duration `mapstructure:"aggregation_interval"`
+	BufferPoolSize        int           `mapstructure:"buffer_pool_size"`
+	MaxMessagesPerPayload int           `mapstructure:"max_messages_per_payload"`
+	FlushInterval         time.Duration `mapstructure:"flush_interval"`
+	DisableTelemetry      bool          `mapstructure:"disable_telemetry"`
 }
 
-// DatabaseConfig holds database connection specific configuration
+// DatadogAPIConfig holds configuration for submitting to the Datadog HTTP API
+type DatadogAPIConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// APIKey defaults to the DD_API_KEY environment variable
+	APIKey string `mapstructure:"api_key"`
+	// Site is the Datadog site, e.g. datadoghq.eu, defaulting to the DD_SITE
+	// environment variable or datadoghq.com
+	Site string `mapstructure:"site"`
+	// URL overrides the API base URL derived from Site
+	URL  string   `mapstructure:"url"`
+	Tags []string `mapstructure:"tags"`
+	// BatchSize is the number of metrics buffered before they are sent
+	BatchSize     int           `mapstructure:"batch_size"`
+	FlushInterval time.Duration `mapstructure:"flush_interval"`
+	// MaxRetries is nil when not set, as 0 turns retries off
+	MaxRetries   *int          `mapstructure:"max_retries"`
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"`
+	Timeout      time.Duration `mapstructure:"timeout"`
+}
+
+// OTLPConfig holds configuration for exporting metrics over OTLP
+type OTLPConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Protocol is grpc (the default) or http
+	Protocol string `mapstructure:"protocol"`
+	// Endpoint is host:port or a URL, defaulting to the exporter's standard
+	// OTEL_EXPORTER_OTLP_* environment variables
+	Endpoint string            `mapstructure:"endpoint"`
+	Insecure bool              `mapstructure:"insecure"`
+	Headers  map[string]string `mapstructure:"headers"`
+	// ServiceName sets the service.name resource attribute
+	ServiceName string `mapstructure:"service_name"`
+	// Tags are added to the resource attributes
+	Tags           []string      `mapstructure:"tags"`
+	ExportInterval time.Duration `mapstructure:"export_interval"`
+	Timeout        time.Duration `mapstructure:"timeout"`
+}
+
+// InfluxDBConfig holds configuration for writing InfluxDB line protocol
+type InfluxDBConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// URL is the server's http:// or https:// address for the write API, or
+	// udp://host:port for a UDP listener
+	URL string `mapstructure:"url"`
+	// Database is the InfluxDB 1.x database, Org and Bucket are used instead
+	// for InfluxDB 2.x
+	Database string `mapstructure:"database"`
+	Org      string `mapstructure:"org"`
+	Bucket   string `mapstructure:"bucket"`
+	// Token is sent as an Authorization header, defaulting to the
+	// INFLUXDB_TOKEN environment variable
+	Token         string        `mapstructure:"token"`
+	Tags          []string      `mapstructure:"tags"`
+	BatchSize     int           `mapstructure:"batch_size"`
+	FlushInterval time.Duration `mapstructure:"flush_interval"`
+	Timeout       time.Duration `mapstructure:"timeout"`
+}
+
+// GraphiteConfig holds configuration for the Graphite plaintext protocol
+type GraphiteConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Address is the host:port of the Graphite plaintext TCP listener
+	Address string `mapstructure:"address"`
+	// Template is a text/template building the metric path from the metric
+	// and its tags
+	Template string        `mapstructure:"template"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+}
+
+// JSONLinesConfig holds configuration for writing metrics and events as JSON
+// lines
+type JSONLinesConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Path is the file to write to, or - (the default) for stdout
+	Path string `mapstructure:"path"`
+	// MaxSizeMB is the size a file is rotated at, and MaxBackups the number of
+	// rotated files kept. MaxBackups is nil when not set, as 0 keeps none.
+	MaxSizeMB  int  `mapstructure:"max_size_mb"`
+	MaxBackups *int `mapstructure:"max_backups"`
+}
+
+// WebhookConfig holds configuration for posting events to a webhook
+type WebhookConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	URL     string `mapstructure:"url"`
+	// Template is a text/template rendering the event into the JSON body
+	Template string            `mapstructure:"template"`
+	Headers  map[string]string `mapstructure:"headers"`
+	// MaxRetries is nil when not set, as 0 turns retries off
+	MaxRetries   *int          `mapstructure:"max_retries"`
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"`
+	Timeout      time.Duration `mapstructure:"timeout"`
+}
+
+// DefaultWebhookTemplate posts every field of the event as a JSON object
+const DefaultWebhookTemplate = `{"monitor": {{json .Monitor}}, "title": {{json .Title}}, "text": {{json .Text}}, ` +
+	`"alert_type": {{json .AlertType}}, "priority": {{json .Priority}}, "aggregation_key": {{json .AggregationKey}}, ` +
+	`"hostname": {{json .Hostname}}, "tags": {{json .Tags}}, "timestamp": {{json .Timestamp}}}`
+
+// DefaultGraphiteTemplate appends each tag value to the metric name, in the
+// order the tags are sent
+const DefaultGraphiteTemplate = "{{.Name}}{{range .Tags}}.{{.Value}}{{end}}"
+
+// DatabaseConfig holds database connection specific configuration. The
+// connection is either given as a driver specific URI, or assembled from the
+// Host and the settings after it, so passwords don't need to be URL-encoded.
 type DatabaseConfig struct {
 	Type string `mapstructure:"type"`
 	URI  string `mapstructure:"uri"`
+
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	User     string `mapstructure:"user"`
+	Password string `mapstructure:"password"`
+	Database string `mapstructure:"database"`
+	// SSLMode is one of disable, require, verify-ca or verify-full, as in
+	// Postgres, and is translated for the other drivers
+	SSLMode string `mapstructure:"sslmode"`
+	TLSCA   string `mapstructure:"tls_ca"`
+	TLSCert string `mapstructure:"tls_cert"`
+	TLSKey  string `mapstructure:"tls_key"`
+	// Options are added to the connection string as driver parameters
+	Options map[string]string `mapstructure:"options"`
+
+	// MaxConcurrentQueries caps how many monitors query the database at once,
+	// shared by every monitor with the same Key. 0 is unlimited.
+	MaxConcurrentQueries int `mapstructure:"max_concurrent_queries"`
+}
+
+// readOnlyTransactionTypes are the database types whose drivers start
+// read-only transactions. The others either reject sql.TxOptions.ReadOnly,
+// like SQL Server, or silently ignore it.
+var readOnlyTransactionTypes = map[string]bool{
+	"postgres": true,
+	"mysql":    true,
+	"vertica":  true,
+}
+
+// UsesReadOnlyTransaction reports whether the database's queries run in a
+// read-only transaction
+func (d DatabaseConfig) UsesReadOnlyTransaction() bool {
+	return readOnlyTransactionTypes[d.Type]
 }
 
+// EnforcesReadOnly reports whether the database's queries are kept from
+// changing data: in a read-only transaction, or for clickhouse when the uri or
+// options set its readonly setting
+func (d DatabaseConfig) EnforcesReadOnly() bool {
+	if d.UsesReadOnlyTransaction() {
+		return true
+	}
+	if d.Type != "clickhouse" {
+		return false
+	}
+
+	readonly := d.Options["readonly"]
+	if u, err := url.Parse(d.URI); err == nil && d.URI != "" {
+		for key, values := range u.Query() {
+			if strings.EqualFold(key, "readonly") {
+				readonly = values[0]
+			}
+		}
+	}
+
+	return readonly != "" && readonly != "0"
+}
+
+// Key identifies the database the monitor connects to: the uri, or the host
+// and port
+func (d DatabaseConfig) Key() string {
+	if d.Host == "" {
+		return d.Type + " " + d.URI
+	}
+
+	return fmt.Sprintf("%s %s:%d", d.Type, d.Host, d.Port)
+}
+
+// Redacted returns a copy of the config with the password, including any in
+// the URI, replaced so it can be printed
+func (d DatabaseConfig) Redacted() DatabaseConfig {
+	if d.Password != "" {
+		d.Password = redactedPassword
+	}
+
+	if u, err := url.Parse(d.URI); err == nil && strings.Contains(d.URI, "://") {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redactedPassword)
+			d.URI = u.String()
+		}
+	} else {
+		// MySQL style user:password@tcp(host)/database
+		d.URI = uriUserPassword.ReplaceAllString(d.URI, "${1}:"+redactedPassword+"@${2}")
+	}
+	// Passwords given as parameters, as in SQL Server and key=value DSNs
+	d.URI = uriPasswordParam.ReplaceAllString(d.URI, "${1}="+redactedPassword)
+
+	return d
+}
+
+const redactedPassword = "xxxxx"
+
+var (
+	uriUserPassword  = regexp.MustCompile(`^([^:/@]*):.*@([a-z]*\(|/)`)
+	uriPasswordParam = regexp.MustCompile(`(?i)\b(password|pwd)=[^;&\s]*`)
+)
+
 // MonitorConfig holds Monitor specific configuration
 type MonitorConfig struct {
 	Name           string         `mapstructure:"name"`
+	Profile        string         `mapstructure:"profile"`
 	DatabaseConfig DatabaseConfig `mapstructure:"database"`
 	SleepDuration  int            `mapstructure:"sleep_duration"`
 	Metric         string         `mapstructure:"metric"`
 	MetricType     string         `mapstructure:"metric_type"`
-	EventConfig    EventConfig    `mapstructure:"event"`
-	SQL            string         `mapstructure:"sql"`
+	SampleRate     float64        `mapstructure:"sample_rate"`
+	// Namespace is prepended to Metric, and Tags are attached to everything
+	// the monitor sends (metrics, events and self-telemetry)
+	Namespace string   `mapstructure:"namespace"`
+	Tags      []string `mapstructure:"tags"`
+	// TagColumns limits the SQL columns used as metric tags, ExcludeColumns
+	// drops columns from them, and TagAliases renames a column's tag key
+	TagColumns     []string          `mapstructure:"tag_columns"`
+	ExcludeColumns []string          `mapstructure:"exclude_columns"`
+	TagAliases     map[string]string `mapstructure:"tag_aliases"`
+	// TagNormalization controls how SQL values are turned into tags, and
+	// MaxTagCombinations caps the distinct metric tag sets sent per run
+	TagNormalization   TagNormalizationConfig `mapstructure:"tag_normalization"`
+	MaxTagCombinations int                    `mapstructure:"max_tag_combinations"`
+	EventConfig        EventConfig            `mapstructure:"event"`
+	SQL                string                 `mapstructure:"sql"`
+	// SQLFile is a path to a file containing the query, relative to the
+	// config file the monitor is defined in. It is read into SQL on load.
+	SQLFile string `mapstructure:"sql_file"`
+	// SessionSQL are statements, such as SET statement_timeout, run before
+	// the query on the same connection and in the same transaction
+	SessionSQL []string `mapstructure:"session_sql"`
+	// RequireReadOnly refuses to load the monitor unless its database type
+	// can enforce read-only queries
+	RequireReadOnly bool `mapstructure:"require_read_only"`
+	// MaxRetries is how many times a query failing with a transient error is
+	// retried within a run, waiting RetryBackoff before the first retry and
+	// doubling it, up to MaxRetryBackoff, before each one after. MaxRetries
+	// is nil when not set, as 0 turns retries off.
+	MaxRetries      *int          `mapstructure:"max_retries"`
+	RetryBackoff    time.Duration `mapstructure:"retry_backoff"`
+	MaxRetryBackoff time.Duration `mapstructure:"max_retry_backoff"`
+	// MaxFailureBackoff caps how far the sleep between runs is stretched,
+	// doubling with every consecutive failed run after the first
+	MaxFailureBackoff time.Duration `mapstructure:"max_failure_backoff"`
+	// StartJitter delays the first run by a random duration up to this long,
+	// so monitors started together don't keep querying at the same moment
+	StartJitter time.Duration `mapstructure:"start_jitter"`
+	// Source is the path of the file the monitor was defined in
+	Source string `mapstructure:"-"`
+}
+
+// Tag normalization defaults. Datadog truncates tags longer than
+// DefaultTagMaxLength.
+const (
+	DefaultTagMaxLength = 200
+	DefaultTagNullValue = "null"
+)
+
+// TagNormalizationConfig holds the options for turning SQL values into tags.
+// Characters Datadog doesn't allow in tags are always replaced.
+type TagNormalizationConfig struct {
+	Lowercase bool   `mapstructure:"lowercase"`
+	MaxLength int    `mapstructure:"max_length"`
+	NullValue string `mapstructure:"null_value"`
 }
 
 // EventConfig holds Datadog event-specific configuration for a monitor
@@ -75,46 +371,904 @@ type EventConfig struct {
 	HostnameColumn       string   `mapstructure:"hostname_column"`
 	Tags                 []string `mapstructure:"tags"`
 	TagColumns           []string `mapstructure:"tag_columns"`
+	// TitleTemplate and TextTemplate are text/templates rendered for each row,
+	// taking precedence over the title and text columns and static values
+	TitleTemplate string `mapstructure:"title_template"`
+	TextTemplate  string `mapstructure:"text_template"`
+	// MaxEventsPerRun caps the events sent per run, and RateLimit (events per
+	// minute, allowing bursts of RateLimitBurst) limits them across runs. Rows
+	// over either limit are summarized in a single event at the end of the run.
+	MaxEventsPerRun int     `mapstructure:"max_events_per_run"`
+	RateLimit       float64 `mapstructure:"rate_limit"`
+	RateLimitBurst  int     `mapstructure:"rate_limit_burst"`
 }
 
-// Read a config file and return a Config
+// Read a config file, along with any files it includes, and return a Config
 func Read(configPath string) (*Config, error) {
-	configFile, readErr := os.Open(configPath)
-	if readErr != nil {
-		return nil, readErr
+	return Load(configPath, "")
+}
+
+// Load reads the config file at configPath and merges in the monitors defined
+// by every YAML or JSON file in configDir. Either argument may be empty, but
+// not both.
+func Load(configPath string, configDir string) (*Config, error) {
+	if configPath == "" && configDir == "" {
+		return nil, fmt.Errorf("no config file or config directory specified")
+	}
+
+	l := &loader{
+		config: &Config{
+			StatsdConfig: StatsdConfig{Enabled: true},
+		},
+		visited: make(map[string]struct{}),
+	}
+
+	if configPath != "" {
+		if err := l.addMainFile(configPath); err != nil {
+			return nil, err
+		}
+	}
+
+	if configDir != "" {
+		paths, err := configDirFiles(configDir)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := l.addIncludedFiles(paths); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := l.resolveMonitors(); err != nil {
+		return nil, err
+	}
+
+	if err := finalize(l.config); err != nil {
+		return nil, err
+	}
+
+	return l.config, nil
+}
+
+// mainFileKeys are the top-level sections only the main config file may set
+var mainFileKeys = []string{"statsd", "datadog_api", "otlp", "influxdb", "graphite", "json_lines", "webhook", "defaults", "profiles"}
+
+// loader accumulates a main config file and the files it includes before
+// their monitors are resolved into a Config
+type loader struct {
+	config   *Config
+	defaults map[string]interface{}
+	profiles map[string]interface{}
+	monitors []rawMonitor
+	visited  map[string]struct{}
+}
+
+// rawMonitor holds a monitor's settings as read from its file, before the
+// defaults and its profile are merged in
+type rawMonitor struct {
+	source   string
+	settings map[string]interface{}
+}
+
+func (l *loader) addMainFile(path string) error {
+	v, err := readFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := v.UnmarshalKey("statsd", &l.config.StatsdConfig); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := v.UnmarshalKey("datadog_api", &l.config.DatadogAPIConfig); err != nil {
+		return fmt.Errorf("%s: datadog_api: %w", path, err)
+	}
+	if err := v.UnmarshalKey("otlp", &l.config.OTLPConfig); err != nil {
+		return fmt.Errorf("%s: otlp: %w", path, err)
+	}
+	if err := v.UnmarshalKey("influxdb", &l.config.InfluxDBConfig); err != nil {
+		return fmt.Errorf("%s: influxdb: %w", path, err)
+	}
+	if err := v.UnmarshalKey("graphite", &l.config.GraphiteConfig); err != nil {
+		return fmt.Errorf("%s: graphite: %w", path, err)
+	}
+	if err := v.UnmarshalKey("json_lines", &l.config.JSONLinesConfig); err != nil {
+		return fmt.Errorf("%s: json_lines: %w", path, err)
+	}
+	if err := v.UnmarshalKey("webhook", &l.config.WebhookConfig); err != nil {
+		return fmt.Errorf("%s: webhook: %w", path, err)
+	}
+	if err := v.UnmarshalKey("defaults", &l.config.Defaults); err != nil {
+		return fmt.Errorf("%s: defaults: %w", path, err)
+	}
+	if err := v.UnmarshalKey("profiles", &l.config.Profiles); err != nil {
+		return fmt.Errorf("%s: profiles: %w", path, err)
+	}
+
+	l.defaults = v.GetStringMap("defaults")
+	l.profiles = v.GetStringMap("profiles")
+	for name, profile := range l.profiles {
+		if profileMap, ok := profile.(map[string]interface{}); ok && profileMap["profile"] != nil {
+			return fmt.Errorf("%s: profiles: %s: a profile cannot set profile", path, name)
+		}
+	}
+
+	return l.addFile(path, v)
+}
+
+// addFile collects the monitors of an already parsed file and follows its
+// include globs, which are resolved relative to the file.
+func (l *loader) addFile(path string, v *viper.Viper) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	l.visited[absPath] = struct{}{}
+
+	if monitors := v.Get("monitors"); monitors != nil {
+		list, ok := monitors.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: monitors must be a list", path)
+		}
+
+		for i, item := range list {
+			settings, ok := item.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s: monitor %d must be a map", path, i)
+			}
+			l.monitors = append(l.monitors, rawMonitor{source: path, settings: settings})
+		}
+	}
+
+	includes := v.GetStringSlice("include")
+	l.config.Include = append(l.config.Include, includes...)
+
+	var paths []string
+	for _, pattern := range includes {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("%s: invalid include pattern %q: %w", path, pattern, err)
+		}
+		sort.Strings(matches)
+		paths = append(paths, matches...)
 	}
 
-	viper.SetConfigType("yaml")
-	parseErr := viper.ReadConfig(configFile)
-	if parseErr != nil {
-		return nil, parseErr
+	return l.addIncludedFiles(paths)
+}
+
+// addIncludedFiles reads each file in paths and collects its monitors.
+// Included files may only define monitors and further includes.
+func (l *loader) addIncludedFiles(paths []string) error {
+	for _, path := range paths {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		if _, ok := l.visited[absPath]; ok {
+			continue
+		}
+
+		v, err := readFile(path)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range mainFileKeys {
+			if v.IsSet(key) {
+				return fmt.Errorf("%s: %s may only be configured in the main config file", path, key)
+			}
+		}
+
+		if err := l.addFile(path, v); err != nil {
+			return err
+		}
 	}
 
-	config := &Config{}
+	return nil
+}
+
+// resolveMonitors merges the defaults and the named profile into each
+// monitor's own settings and decodes the result.
+func (l *loader) resolveMonitors() error {
+	for _, raw := range l.monitors {
+		settings := mergeSettings(nil, l.defaults)
+
+		// The monitor's profile, or else the one set in defaults
+		profileName, _ := raw.settings["profile"].(string)
+		if profileName == "" {
+			profileName, _ = settings["profile"].(string)
+		}
+		if profileName != "" {
+			// Viper lower-cases map keys, so profile names are case-insensitive
+			profile, ok := l.profiles[strings.ToLower(profileName)].(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s: monitor %q: unknown profile: %s", raw.source, raw.settings["name"], profileName)
+			}
+			settings = mergeMonitorSettings(settings, profile)
+		}
+
+		settings = mergeMonitorSettings(settings, raw.settings)
 
-	unmarshalErr := viper.Unmarshal(config)
-	if unmarshalErr != nil {
-		return nil, unmarshalErr
+		monitorConfig := MonitorConfig{}
+		if err := decodeSettings(settings, &monitorConfig); err != nil {
+			return fmt.Errorf("%s: monitor %q: %w", raw.source, raw.settings["name"], err)
+		}
+		monitorConfig.Source = raw.source
+
+		l.config.Monitors = append(l.config.Monitors, monitorConfig)
 	}
 
-	// Set default metric types and normalize case for backwards compatibility
+	return nil
+}
+
+// connectionSettings are the database settings assembling a connection from
+// its host, which replace an inherited uri and are replaced by one
+var connectionSettings = []string{"host", "port", "user", "password", "database", "sslmode", "tls_ca", "tls_cert", "tls_key", "options"}
+
+// mergeMonitorSettings merges monitor settings like mergeSettings, except that
+// the two ways of giving a database connection replace each other: a database
+// uri in override drops the inherited host and its settings, and a host or
+// any of its settings drops the inherited uri.
+func mergeMonitorSettings(base map[string]interface{}, override map[string]interface{}) map[string]interface{} {
+	baseDatabase, baseIsMap := base["database"].(map[string]interface{})
+	overrideDatabase, overrideIsMap := override["database"].(map[string]interface{})
+	if !baseIsMap || !overrideIsMap {
+		return mergeSettings(base, override)
+	}
+
+	var replaced []string
+	if _, ok := overrideDatabase["uri"]; ok {
+		replaced = connectionSettings
+	} else {
+		for _, key := range connectionSettings {
+			if _, ok := overrideDatabase[key]; ok {
+				replaced = []string{"uri"}
+				break
+			}
+		}
+	}
+	if len(replaced) == 0 {
+		return mergeSettings(base, override)
+	}
+
+	database := mergeSettings(nil, baseDatabase)
+	for _, key := range replaced {
+		delete(database, key)
+	}
+
+	trimmed := mergeSettings(nil, base)
+	trimmed["database"] = database
+
+	return mergeSettings(trimmed, override)
+}
+
+// mergeSettings returns a deep merge of override on top of base. Nested maps
+// are merged key by key, any other value in override replaces the one in
+// base. Neither argument is modified.
+func mergeSettings(base map[string]interface{}, override map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(override))
+	for key, value := range base {
+		merged[key] = value
+	}
+
+	for key, value := range override {
+		baseMap, baseIsMap := merged[key].(map[string]interface{})
+		overrideMap, overrideIsMap := value.(map[string]interface{})
+		if baseIsMap && overrideIsMap {
+			merged[key] = mergeSettings(baseMap, overrideMap)
+			continue
+		}
+
+		merged[key] = value
+	}
+
+	return merged
+}
+
+// decodeSettings decodes a settings map the same way viper.Unmarshal does
+func decodeSettings(settings map[string]interface{}, result interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+		WeaklyTypedInput: true,
+		Result:           result,
+	})
+	if err != nil {
+		return err
+	}
+
+	return decoder.Decode(settings)
+}
+
+// readFile parses a single YAML or JSON config file. The file type is taken
+// from the extension, defaulting to YAML.
+func readFile(path string) (*viper.Viper, error) {
+	configFile, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer configFile.Close()
+
+	v := viper.New()
+	v.SetConfigType(configType(path))
+	if err := v.ReadConfig(configFile); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return v, nil
+}
+
+func configType(path string) string {
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		return "json"
+	}
+
+	return "yaml"
+}
+
+// configDirFiles returns the YAML and JSON files in dir, sorted by name
+func configDirFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".yml", ".yaml", ".json":
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	return paths, nil
+}
+
+// finalize applies defaults to the merged monitors and validates them
+func finalize(config *Config) error {
+	if err := normalizeStatsdConfig(&config.StatsdConfig); err != nil {
+		return fmt.Errorf("statsd: %w", err)
+	}
+
+	if err := normalizeDatadogAPIConfig(&config.DatadogAPIConfig); err != nil {
+		return fmt.Errorf("datadog_api: %w", err)
+	}
+
+	if err := normalizeOTLPConfig(&config.OTLPConfig); err != nil {
+		return fmt.Errorf("otlp: %w", err)
+	}
+
+	if err := normalizeInfluxDBConfig(&config.InfluxDBConfig); err != nil {
+		return fmt.Errorf("influxdb: %w", err)
+	}
+
+	if err := normalizeGraphiteConfig(&config.GraphiteConfig); err != nil {
+		return fmt.Errorf("graphite: %w", err)
+	}
+
+	if err := normalizeJSONLinesConfig(&config.JSONLinesConfig); err != nil {
+		return fmt.Errorf("json_lines: %w", err)
+	}
+
+	if err := normalizeWebhookConfig(&config.WebhookConfig); err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+
+	if !config.StatsdConfig.Enabled && !config.DatadogAPIConfig.Enabled && !config.OTLPConfig.Enabled &&
+		!config.InfluxDBConfig.Enabled && !config.GraphiteConfig.Enabled && !config.JSONLinesConfig.Enabled &&
+		!config.WebhookConfig.Enabled {
+		return fmt.Errorf("no sinks are enabled")
+	}
+
+	sources := make(map[string]string, len(config.Monitors))
+	// The first monitor to set a database's max_concurrent_queries, by Key
+	concurrencyLimits := make(map[string]*MonitorConfig)
+
 	for i := range config.Monitors {
-		if config.Monitors[i].MetricType == "" {
-			config.Monitors[i].MetricType = "gauge"
+		monitorConfig := &config.Monitors[i]
+
+		if source, ok := sources[monitorConfig.Name]; ok {
+			return fmt.Errorf("%s: duplicate monitor name %q (already defined in %s)",
+				monitorConfig.Source, monitorConfig.Name, source)
+		}
+		sources[monitorConfig.Name] = monitorConfig.Source
+
+		// Set default metric types and normalize case for backwards compatibility
+		if monitorConfig.MetricType == "" {
+			monitorConfig.MetricType = "gauge"
 		} else {
-			config.Monitors[i].MetricType = strings.ToLower(config.Monitors[i].MetricType)
+			monitorConfig.MetricType = strings.ToLower(monitorConfig.MetricType)
+		}
+
+		if err := validateMetricType(monitorConfig.MetricType); err != nil {
+			return fmt.Errorf("%s: monitor %q: %w", monitorConfig.Source, monitorConfig.Name, err)
+		}
+
+		// Only the agent counts unique values, InfluxDB and JSON lines record
+		// them as strings, the other sinks have no set type
+		if monitorConfig.MetricType == "set" && !config.StatsdConfig.Enabled &&
+			!config.InfluxDBConfig.Enabled && !config.JSONLinesConfig.Enabled {
+			return fmt.Errorf("%s: monitor %q: set metrics require statsd, influxdb or json_lines", monitorConfig.Source, monitorConfig.Name)
+		}
+
+		if monitorConfig.SampleRate == 0 {
+			monitorConfig.SampleRate = 1
+		} else if monitorConfig.SampleRate < 0 || monitorConfig.SampleRate > 1 {
+			return fmt.Errorf("%s: monitor %q: sample_rate must be between 0 and 1: %v",
+				monitorConfig.Source, monitorConfig.Name, monitorConfig.SampleRate)
+		}
+
+		if err := normalizeDatabaseConfig(&monitorConfig.DatabaseConfig); err != nil {
+			return fmt.Errorf("%s: monitor %q: %w", monitorConfig.Source, monitorConfig.Name, err)
+		}
+
+		if monitorConfig.RequireReadOnly && !monitorConfig.DatabaseConfig.EnforcesReadOnly() {
+			return fmt.Errorf("%s: monitor %q: require_read_only is set but %s cannot enforce read-only queries",
+				monitorConfig.Source, monitorConfig.Name, monitorConfig.DatabaseConfig.Type)
+		}
+
+		// Monitors share the limit, so they can't disagree on it
+		if monitorConfig.DatabaseConfig.MaxConcurrentQueries > 0 {
+			key := monitorConfig.DatabaseConfig.Key()
+			if other, ok := concurrencyLimits[key]; !ok {
+				concurrencyLimits[key] = monitorConfig
+			} else if other.DatabaseConfig.MaxConcurrentQueries != monitorConfig.DatabaseConfig.MaxConcurrentQueries {
+				return fmt.Errorf("%s: monitor %q: database max_concurrent_queries %d differs from %d set by monitor %q for the same database",
+					monitorConfig.Source, monitorConfig.Name, monitorConfig.DatabaseConfig.MaxConcurrentQueries,
+					other.DatabaseConfig.MaxConcurrentQueries, other.Name)
+			}
+		}
+
+		if err := loadSQLFile(monitorConfig); err != nil {
+			return fmt.Errorf("%s: monitor %q: %w", monitorConfig.Source, monitorConfig.Name, err)
+		}
+
+		if err := validateTagColumns(*monitorConfig); err != nil {
+			return fmt.Errorf("%s: monitor %q: %w", monitorConfig.Source, monitorConfig.Name, err)
+		}
+
+		if err := normalizeRetries(monitorConfig); err != nil {
+			return fmt.Errorf("%s: monitor %q: %w", monitorConfig.Source, monitorConfig.Name, err)
+		}
+
+		normalizeTagNormalizationConfig(&monitorConfig.TagNormalization)
+		if monitorConfig.MaxTagCombinations < 0 {
+			return fmt.Errorf("%s: monitor %q: max_tag_combinations cannot be negative",
+				monitorConfig.Source, monitorConfig.Name)
+		}
+
+		if monitorConfig.StartJitter < 0 {
+			return fmt.Errorf("%s: monitor %q: start_jitter cannot be negative",
+				monitorConfig.Source, monitorConfig.Name)
+		}
+		if belowMillisecond(monitorConfig.StartJitter) {
+			return fmt.Errorf("%s: monitor %q: start_jitter must be at least 1ms, use a duration such as 5m",
+				monitorConfig.Source, monitorConfig.Name)
 		}
 
-		if config.Monitors[i].EventConfig.Enabled {
-			normalizeEventConfig(&config.Monitors[i].EventConfig)
+		if monitorConfig.EventConfig.Enabled {
+			normalizeEventConfig(&monitorConfig.EventConfig)
 
-			if err := validateEventConfig(config.Monitors[i].EventConfig); err != nil {
-				return nil, fmt.Errorf("monitor %q: %w", config.Monitors[i].Name, err)
+			if err := validateEventConfig(monitorConfig.EventConfig); err != nil {
+				return fmt.Errorf("%s: monitor %q: %w", monitorConfig.Source, monitorConfig.Name, err)
 			}
 		}
 	}
 
-	return config, nil
+	return nil
+}
+
+// loadSQLFile reads the monitor's sql_file, if it has one, into its SQL
+func loadSQLFile(monitorConfig *MonitorConfig) error {
+	if monitorConfig.SQLFile == "" {
+		return nil
+	}
+
+	if monitorConfig.SQL != "" {
+		return fmt.Errorf("sql and sql_file cannot both be set")
+	}
+
+	path := monitorConfig.SQLFile
+	if !filepath.IsAbs(path) && monitorConfig.Source != "" {
+		path = filepath.Join(filepath.Dir(monitorConfig.Source), path)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read sql_file: %w", err)
+	}
+
+	monitorConfig.SQL = strings.TrimSpace(string(contents))
+	if monitorConfig.SQL == "" {
+		return fmt.Errorf("sql_file is empty: %s", path)
+	}
+
+	return nil
+}
+
+func normalizeStatsdConfig(statsdConfig *StatsdConfig) error {
+	if scheme, _, found := strings.Cut(statsdConfig.Address, "://"); found {
+		switch scheme {
+		case "udp", "unix", "unixgram", "unixstream":
+		default:
+			return fmt.Errorf("unsupported address scheme: %s", scheme)
+		}
+	}
+
+	if statsdConfig.Aggregation == "" {
+		statsdConfig.Aggregation = "basic"
+	} else {
+		statsdConfig.Aggregation = strings.ToLower(statsdConfig.Aggregation)
+	}
+
+	switch statsdConfig.Aggregation {
+	case "basic", "extended", "none":
+	default:
+		return fmt.Errorf("unknown aggregation mode: %s", statsdConfig.Aggregation)
+	}
+
+	if statsdConfig.BufferPoolSize < 0 || statsdConfig.MaxMessagesPerPayload < 0 ||
+		statsdConfig.FlushInterval < 0 || statsdConfig.AggregationInterval < 0 {
+		return fmt.Errorf("client options cannot be negative")
+	}
+	if belowMillisecond(statsdConfig.FlushInterval, statsdConfig.AggregationInterval) {
+		return fmt.Errorf("flush_interval and aggregation_interval must be at least 1ms, use a duration such as 2s")
+	}
+
+	return nil
+}
+
+// belowMillisecond reports whether any of the durations is set but under 1ms.
+// A bare number is read as nanoseconds, which is never what was meant.
+func belowMillisecond(durations ...time.Duration) bool {
+	for _, duration := range durations {
+		if duration > 0 && duration < time.Millisecond {
+			return true
+		}
+	}
+
+	return false
+}
+
+func normalizeDatadogAPIConfig(apiConfig *DatadogAPIConfig) error {
+	if !apiConfig.Enabled {
+		return nil
+	}
+
+	if apiConfig.APIKey == "" {
+		apiConfig.APIKey = os.Getenv("DD_API_KEY")
+	}
+	if apiConfig.APIKey == "" {
+		return fmt.Errorf("api_key or DD_API_KEY must be set")
+	}
+
+	if apiConfig.Site == "" {
+		apiConfig.Site = os.Getenv("DD_SITE")
+	}
+	if apiConfig.Site == "" {
+		apiConfig.Site = "datadoghq.com"
+	}
+	if apiConfig.URL == "" {
+		apiConfig.URL = "https://api." + apiConfig.Site
+	}
+	apiConfig.URL = strings.TrimRight(apiConfig.URL, "/")
+
+	if apiConfig.BatchSize < 0 || apiConfig.FlushInterval < 0 || (apiConfig.MaxRetries != nil && *apiConfig.MaxRetries < 0) ||
+		apiConfig.RetryBackoff < 0 || apiConfig.Timeout < 0 {
+		return fmt.Errorf("options cannot be negative")
+	}
+	if belowMillisecond(apiConfig.FlushInterval, apiConfig.RetryBackoff, apiConfig.Timeout) {
+		return fmt.Errorf("flush_interval, retry_backoff and timeout must be at least 1ms, use a duration such as 10s")
+	}
+	if apiConfig.BatchSize == 0 {
+		apiConfig.BatchSize = 500
+	}
+	if apiConfig.FlushInterval == 0 {
+		apiConfig.FlushInterval = 10 * time.Second
+	}
+	if apiConfig.MaxRetries == nil {
+		maxRetries := 3
+		apiConfig.MaxRetries = &maxRetries
+	}
+	if apiConfig.RetryBackoff == 0 {
+		apiConfig.RetryBackoff = time.Second
+	}
+	if apiConfig.Timeout == 0 {
+		apiConfig.Timeout = 10 * time.Second
+	}
+
+	return nil
+}
+
+func normalizeOTLPConfig(otlpConfig *OTLPConfig) error {
+	if !otlpConfig.Enabled {
+		return nil
+	}
+
+	if otlpConfig.Protocol == "" {
+		otlpConfig.Protocol = "grpc"
+	} else {
+		otlpConfig.Protocol = strings.ToLower(otlpConfig.Protocol)
+	}
+
+	switch otlpConfig.Protocol {
+	case "grpc", "http":
+	default:
+		return fmt.Errorf("unknown protocol: %s", otlpConfig.Protocol)
+	}
+
+	if otlpConfig.ServiceName == "" {
+		otlpConfig.ServiceName = "anemometer"
+	}
+
+	if otlpConfig.ExportInterval < 0 || otlpConfig.Timeout < 0 {
+		return fmt.Errorf("options cannot be negative")
+	}
+	if belowMillisecond(otlpConfig.ExportInterval, otlpConfig.Timeout) {
+		return fmt.Errorf("export_interval and timeout must be at least 1ms, use a duration such as 10s")
+	}
+	if otlpConfig.ExportInterval == 0 {
+		otlpConfig.ExportInterval = 10 * time.Second
+	}
+	if otlpConfig.Timeout == 0 {
+		otlpConfig.Timeout = 10 * time.Second
+	}
+
+	return nil
+}
+
+func normalizeInfluxDBConfig(influxConfig *InfluxDBConfig) error {
+	if !influxConfig.Enabled {
+		return nil
+	}
+
+	scheme, _, _ := strings.Cut(influxConfig.URL, "://")
+	switch scheme {
+	case "http", "https":
+		if influxConfig.Database == "" && influxConfig.Bucket == "" {
+			return fmt.Errorf("database or bucket must be set")
+		}
+	case "udp":
+	default:
+		return fmt.Errorf("url must start with http://, https:// or udp://")
+	}
+	influxConfig.URL = strings.TrimRight(influxConfig.URL, "/")
+
+	if influxConfig.Token == "" {
+		influxConfig.Token = os.Getenv("INFLUXDB_TOKEN")
+	}
+
+	if influxConfig.BatchSize < 0 || influxConfig.FlushInterval < 0 || influxConfig.Timeout < 0 {
+		return fmt.Errorf("options cannot be negative")
+	}
+	if belowMillisecond(influxConfig.FlushInterval, influxConfig.Timeout) {
+		return fmt.Errorf("flush_interval and timeout must be at least 1ms, use a duration such as 10s")
+	}
+	if influxConfig.BatchSize == 0 {
+		influxConfig.BatchSize = 1000
+	}
+	if influxConfig.FlushInterval == 0 {
+		influxConfig.FlushInterval = 10 * time.Second
+	}
+	if influxConfig.Timeout == 0 {
+		influxConfig.Timeout = 10 * time.Second
+	}
+
+	return nil
+}
+
+func normalizeGraphiteConfig(graphiteConfig *GraphiteConfig) error {
+	if !graphiteConfig.Enabled {
+		return nil
+	}
+
+	if graphiteConfig.Address == "" {
+		return fmt.Errorf("address must be set")
+	}
+
+	if graphiteConfig.Template == "" {
+		graphiteConfig.Template = DefaultGraphiteTemplate
+	}
+	if _, err := template.New("graphite").Parse(graphiteConfig.Template); err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+
+	if graphiteConfig.Timeout < 0 {
+		return fmt.Errorf("timeout cannot be negative")
+	}
+	if belowMillisecond(graphiteConfig.Timeout) {
+		return fmt.Errorf("timeout must be at least 1ms, use a duration such as 10s")
+	}
+	if graphiteConfig.Timeout == 0 {
+		graphiteConfig.Timeout = 10 * time.Second
+	}
+
+	return nil
+}
+
+func normalizeJSONLinesConfig(jsonLinesConfig *JSONLinesConfig) error {
+	if !jsonLinesConfig.Enabled {
+		return nil
+	}
+
+	if jsonLinesConfig.Path == "" {
+		jsonLinesConfig.Path = "-"
+	}
+
+	if jsonLinesConfig.MaxSizeMB < 0 || (jsonLinesConfig.MaxBackups != nil && *jsonLinesConfig.MaxBackups < 0) {
+		return fmt.Errorf("options cannot be negative")
+	}
+	if jsonLinesConfig.MaxSizeMB == 0 {
+		jsonLinesConfig.MaxSizeMB = 100
+	}
+	if jsonLinesConfig.MaxBackups == nil {
+		maxBackups := 5
+		jsonLinesConfig.MaxBackups = &maxBackups
+	}
+
+	return nil
+}
+
+func normalizeWebhookConfig(webhookConfig *WebhookConfig) error {
+	if !webhookConfig.Enabled {
+		return nil
+	}
+
+	if !strings.HasPrefix(webhookConfig.URL, "http://") && !strings.HasPrefix(webhookConfig.URL, "https://") {
+		return fmt.Errorf("url must start with http:// or https://")
+	}
+
+	if webhookConfig.Template == "" {
+		webhookConfig.Template = DefaultWebhookTemplate
+	}
+	if _, err := template.New("webhook").Funcs(TemplateFuncs).Parse(webhookConfig.Template); err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+
+	if (webhookConfig.MaxRetries != nil && *webhookConfig.MaxRetries < 0) || webhookConfig.RetryBackoff < 0 ||
+		webhookConfig.Timeout < 0 {
+		return fmt.Errorf("options cannot be negative")
+	}
+	if belowMillisecond(webhookConfig.RetryBackoff, webhookConfig.Timeout) {
+		return fmt.Errorf("retry_backoff and timeout must be at least 1ms, use a duration such as 10s")
+	}
+	if webhookConfig.MaxRetries == nil {
+		maxRetries := 3
+		webhookConfig.MaxRetries = &maxRetries
+	}
+	if webhookConfig.RetryBackoff == 0 {
+		webhookConfig.RetryBackoff = time.Second
+	}
+	if webhookConfig.Timeout == 0 {
+		webhookConfig.Timeout = 10 * time.Second
+	}
+
+	return nil
+}
+
+func normalizeDatabaseConfig(databaseConfig *DatabaseConfig) error {
+	if databaseConfig.MaxConcurrentQueries < 0 {
+		return fmt.Errorf("database max_concurrent_queries cannot be negative")
+	}
+
+	databaseConfig.SSLMode = strings.ToLower(databaseConfig.SSLMode)
+	switch databaseConfig.SSLMode {
+	case "", "disable", "require", "verify-ca", "verify-full":
+	default:
+		return fmt.Errorf("unknown database sslmode: %s", databaseConfig.SSLMode)
+	}
+
+	structured := databaseConfig.Host != "" || databaseConfig.Port != 0 || databaseConfig.User != "" ||
+		databaseConfig.Password != "" || databaseConfig.Database != "" || databaseConfig.SSLMode != "" ||
+		databaseConfig.TLSCA != "" || databaseConfig.TLSCert != "" || databaseConfig.TLSKey != "" ||
+		len(databaseConfig.Options) > 0
+	if !structured {
+		return nil
+	}
+
+	if databaseConfig.URI != "" {
+		return fmt.Errorf("database uri cannot be combined with host and the other connection settings")
+	}
+
+	if databaseConfig.Host == "" {
+		return fmt.Errorf("database host must be set")
+	}
+
+	switch databaseConfig.Type {
+	case "postgres", "mysql", "sqlserver", "clickhouse", "vertica":
+	default:
+		return fmt.Errorf("database host is not supported for %s, use uri", databaseConfig.Type)
+	}
+
+	if databaseConfig.Port < 0 || databaseConfig.Port > 65535 {
+		return fmt.Errorf("invalid database port: %d", databaseConfig.Port)
+	}
+
+	if (databaseConfig.TLSCert == "") != (databaseConfig.TLSKey == "") {
+		return fmt.Errorf("database tls_cert and tls_key must be set together")
+	}
+
+	return nil
+}
+
+func normalizeRetries(monitorConfig *MonitorConfig) error {
+	if (monitorConfig.MaxRetries != nil && *monitorConfig.MaxRetries < 0) || monitorConfig.RetryBackoff < 0 ||
+		monitorConfig.MaxRetryBackoff < 0 || monitorConfig.MaxFailureBackoff < 0 {
+		return fmt.Errorf("retry options cannot be negative")
+	}
+	if belowMillisecond(monitorConfig.RetryBackoff, monitorConfig.MaxRetryBackoff) {
+		return fmt.Errorf("retry_backoff and max_retry_backoff must be at least 1ms, use a duration such as 2s")
+	}
+	if monitorConfig.MaxRetries == nil {
+		maxRetries := 3
+		monitorConfig.MaxRetries = &maxRetries
+	}
+	if monitorConfig.RetryBackoff == 0 {
+		monitorConfig.RetryBackoff = time.Second
+	}
+	if monitorConfig.MaxRetryBackoff == 0 {
+		monitorConfig.MaxRetryBackoff = 30 * time.Second
+	}
+	if monitorConfig.MaxRetryBackoff < monitorConfig.RetryBackoff {
+		return fmt.Errorf("max_retry_backoff cannot be less than retry_backoff")
+	}
+	// sleep_duration is in seconds but max_failure_backoff is a duration
+	sleepDuration := time.Duration(monitorConfig.SleepDuration) * time.Second
+	if monitorConfig.MaxFailureBackoff > 0 && monitorConfig.MaxFailureBackoff < sleepDuration {
+		return fmt.Errorf("max_failure_backoff cannot be less than sleep_duration, use a duration such as 1h")
+	}
+	if monitorConfig.MaxFailureBackoff == 0 {
+		monitorConfig.MaxFailureBackoff = time.Hour
+		if monitorConfig.MaxFailureBackoff < sleepDuration {
+			monitorConfig.MaxFailureBackoff = sleepDuration
+		}
+	}
+
+	return nil
+}
+
+func validateMetricType(metricType string) error {
+	switch metricType {
+	case "gauge", "count", "histogram", "distribution", "set", "timing":
+		return nil
+	default:
+		return fmt.Errorf("unknown metric type: %s", metricType)
+	}
+}
+
+func validateTagColumns(monitorConfig MonitorConfig) error {
+	if len(monitorConfig.TagColumns) > 0 && len(monitorConfig.ExcludeColumns) > 0 {
+		return fmt.Errorf("tag_columns and exclude_columns cannot both be set")
+	}
+
+	for _, column := range monitorConfig.TagColumns {
+		switch column {
+		case "metric", "timestamp":
+			return fmt.Errorf("reserved column cannot be used as a tag: %s", column)
+		}
+	}
+
+	return nil
+}
+
+func normalizeTagNormalizationConfig(normalization *TagNormalizationConfig) {
+	if normalization.MaxLength <= 0 {
+		normalization.MaxLength = DefaultTagMaxLength
+	}
+
+	if normalization.NullValue == "" {
+		normalization.NullValue = DefaultTagNullValue
+	}
 }
 
 func normalizeEventConfig(eventConfig *EventConfig) {
@@ -133,6 +1287,10 @@ func normalizeEventConfig(eventConfig *EventConfig) {
 	if eventConfig.SourceTypeName == "" {
 		eventConfig.SourceTypeName = "anemometer"
 	}
+
+	if eventConfig.RateLimit > 0 && eventConfig.RateLimitBurst == 0 {
+		eventConfig.RateLimitBurst = int(math.Ceil(eventConfig.RateLimit))
+	}
 }
 
 func validateEventConfig(eventConfig EventConfig) error {
@@ -148,5 +1306,17 @@ func validateEventConfig(eventConfig EventConfig) error {
 		return fmt.Errorf("unknown event priority: %s", eventConfig.Priority)
 	}
 
+	if eventConfig.MaxEventsPerRun < 0 || eventConfig.RateLimit < 0 || eventConfig.RateLimitBurst < 0 {
+		return fmt.Errorf("event limits cannot be negative")
+	}
+
+	if _, err := template.New("title").Funcs(TemplateFuncs).Parse(eventConfig.TitleTemplate); err != nil {
+		return fmt.Errorf("invalid event title template: %w", err)
+	}
+
+	if _, err := template.New("text").Funcs(TemplateFuncs).Parse(eventConfig.TextTemplate); err != nil {
+		return fmt.Errorf("invalid event text template: %w", err)
+	}
+
 	return nil
 }
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TemplateFuncs are the functions available to the templates in the config
+var TemplateFuncs = template.FuncMap{
+	"json":      toJSON,
+	"truncate":  truncate,
+	"duration":  formatDuration,
+	"codeblock": codeblock,
+}
+
+// toJSON encodes the value as JSON, e.g. to quote a string in a JSON body
+func toJSON(value interface{}) (string, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	return string(encoded), nil
+}
+
+// truncate shortens the value to at most length characters, ending it with
+// "..." when anything was cut off
+func truncate(length int, value interface{}) string {
+	runes := []rune(fmt.Sprint(value))
+	if len(runes) <= length {
+		return string(runes)
+	}
+
+	if length <= 3 {
+		return string(runes[:max(length, 0)])
+	}
+
+	return string(runes[:length-3]) + "..."
+}
+
+// formatDuration formats a number of seconds, or a Go duration, as e.g.
+// 2h3m4s
+func formatDuration(value interface{}) (string, error) {
+	var duration time.Duration
+
+	switch v := value.(type) {
+	case time.Duration:
+		duration = v
+	case string:
+		if seconds, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			duration = time.Duration(seconds * float64(time.Second))
+		} else if parsed, err := time.ParseDuration(strings.TrimSpace(v)); err == nil {
+			duration = parsed
+		} else {
+			return "", fmt.Errorf("duration: cannot parse %q", v)
+		}
+	case int:
+		duration = time.Duration(v) * time.Second
+	case int32:
+		duration = time.Duration(v) * time.Second
+	case int64:
+		duration = time.Duration(v) * time.Second
+	case float32:
+		duration = time.Duration(float64(v) * float64(time.Second))
+	case float64:
+		duration = time.Duration(v * float64(time.Second))
+	default:
+		return "", fmt.Errorf("duration: unsupported value %v (%T)", value, value)
+	}
+
+	if duration < time.Second && duration > -time.Second {
+		return duration.Round(time.Millisecond).String(), nil
+	}
+
+	return duration.Round(time.Second).String(), nil
+}
+
+// codeblock wraps the last argument in a markdown code block, using the first
+// argument as its language when there are two, e.g. {{.Row.query | codeblock "sql"}}
+func codeblock(args ...interface{}) (string, error) {
+	var language, code string
+
+	switch len(args) {
+	case 1:
+		code = fmt.Sprint(args[0])
+	case 2:
+		language = fmt.Sprint(args[0])
+		code = fmt.Sprint(args[1])
+	default:
+		return "", fmt.Errorf("codeblock: expected 1 or 2 arguments, got %d", len(args))
+	}
+
+	return "```" + language + "\n" + strings.TrimRight(code, "\n") + "\n```", nil
+}
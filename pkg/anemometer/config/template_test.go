@@ -0,0 +1,54 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	tests := []struct {
+		name        string
+		template    string
+		data        interface{}
+		expected    string
+		expectedErr string
+	}{
+		{name: "json_string", template: `{{json .}}`, data: "say \"hi\"\n", expected: `"say \"hi\"\n"`},
+		{name: "json_slice", template: `{{json .}}`, data: []string{"a:b"}, expected: `["a:b"]`},
+		{name: "truncate_short", template: `{{truncate 10 .}}`, data: "short", expected: "short"},
+		{name: "truncate_long", template: `{{. | truncate 10}}`, data: "a much longer value", expected: "a much ..."},
+		{name: "truncate_runes", template: `{{truncate 4 .}}`, data: "ééééé", expected: "é..."},
+		{name: "truncate_tiny", template: `{{truncate 2 .}}`, data: "abc", expected: "ab"},
+		{name: "duration_seconds", template: `{{duration .}}`, data: int64(7384), expected: "2h3m4s"},
+		{name: "duration_float", template: `{{duration .}}`, data: 90.4, expected: "1m30s"},
+		{name: "duration_subsecond", template: `{{duration .}}`, data: 0.25, expected: "250ms"},
+		{name: "duration_string_seconds", template: `{{duration .}}`, data: "60", expected: "1m0s"},
+		{name: "duration_string_duration", template: `{{duration .}}`, data: "1h30m", expected: "1h30m0s"},
+		{name: "duration_go_duration", template: `{{duration .}}`, data: 3 * time.Minute, expected: "3m0s"},
+		{name: "duration_invalid", template: `{{duration .}}`, data: "soon", expectedErr: `duration: cannot parse "soon"`},
+		{name: "codeblock", template: `{{codeblock .}}`, data: "SELECT 1\n", expected: "```\nSELECT 1\n```"},
+		{name: "codeblock_language", template: `{{. | codeblock "sql"}}`, data: "SELECT 1", expected: "```sql\nSELECT 1\n```"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New(tt.name).Funcs(TemplateFuncs).Parse(tt.template)
+			assert.NoError(t, err)
+
+			var rendered strings.Builder
+			err = tmpl.Execute(&rendered, tt.data)
+			if tt.expectedErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, rendered.String())
+		})
+	}
+}
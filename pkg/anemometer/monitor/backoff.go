@@ -0,0 +1,59 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/simplifi/anemometer/pkg/anemometer/sink"
+)
+
+// nextInterval is how long to sleep before the next run. A single failure is
+// treated as a blip, after that the sleep doubles with every failed run, up
+// to maxFailureBackoff, but never drops below sleepDuration.
+func (m *Monitor) nextInterval() time.Duration {
+	interval := time.Duration(m.sleepDuration) * time.Second
+
+	for i := 1; i < m.consecutiveFailures && interval < m.maxFailureBackoff; i++ {
+		interval *= 2
+	}
+	if interval > m.maxFailureBackoff && m.maxFailureBackoff > time.Duration(m.sleepDuration)*time.Second {
+		interval = m.maxFailureBackoff
+	}
+
+	return interval
+}
+
+// recordRun counts consecutive failed runs, resetting on success. The count
+// and the interval until the next run are sent while the monitor is failing,
+// and once more when it recovers.
+func (m *Monitor) recordRun(err error) {
+	if err == nil {
+		if m.consecutiveFailures == 0 {
+			return
+		}
+
+		log.Printf("INFO: [%s] Recovered after %d consecutive failure(s)", m.name, m.consecutiveFailures)
+		m.consecutiveFailures = 0
+	} else {
+		m.consecutiveFailures++
+	}
+
+	tags := append([]string{fmt.Sprintf("name:%s", m.name)}, m.tags...)
+	m.sink.SendMetric(sink.Metric{
+		Monitor:    m.name,
+		Name:       "anemometer.consecutive_failures",
+		Type:       "gauge",
+		Value:      float64(m.consecutiveFailures),
+		Tags:       tags,
+		SampleRate: 1,
+	})
+	m.sink.SendMetric(sink.Metric{
+		Monitor:    m.name,
+		Name:       "anemometer.run_interval_seconds",
+		Type:       "gauge",
+		Value:      m.nextInterval().Seconds(),
+		Tags:       tags,
+		SampleRate: 1,
+	})
+}
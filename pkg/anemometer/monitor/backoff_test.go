@@ -0,0 +1,99 @@
+package monitor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	mock_statsd "github.com/DataDog/datadog-go/v5/statsd/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/simplifi/anemometer/pkg/anemometer/sink"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonitorNextInterval(t *testing.T) {
+	tests := []struct {
+		name                string
+		sleepDuration       int
+		maxFailureBackoff   time.Duration
+		consecutiveFailures int
+		expected            time.Duration
+	}{
+		{name: "no_failures", sleepDuration: 300, maxFailureBackoff: time.Hour, expected: 5 * time.Minute},
+		{name: "single_failure", sleepDuration: 300, maxFailureBackoff: time.Hour, consecutiveFailures: 1, expected: 5 * time.Minute},
+		{name: "doubles", sleepDuration: 300, maxFailureBackoff: time.Hour, consecutiveFailures: 3, expected: 20 * time.Minute},
+		{name: "capped", sleepDuration: 300, maxFailureBackoff: time.Hour, consecutiveFailures: 50, expected: time.Hour},
+		{name: "max_below_sleep", sleepDuration: 7200, maxFailureBackoff: time.Hour, consecutiveFailures: 5, expected: 2 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			monitor := &Monitor{
+				sleepDuration:       tt.sleepDuration,
+				maxFailureBackoff:   tt.maxFailureBackoff,
+				consecutiveFailures: tt.consecutiveFailures,
+			}
+
+			assert.Equal(t, tt.expected, monitor.nextInterval())
+		})
+	}
+}
+
+func TestMonitorRecordRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStatsD := mock_statsd.NewMockClientInterface(ctrl)
+	tags := []string{"name:broken", "team:a"}
+	gomock.InOrder(
+		mockStatsD.EXPECT().Gauge("anemometer.consecutive_failures", 1.0, tags, float64(1)).Return(nil),
+		mockStatsD.EXPECT().Gauge("anemometer.run_interval_seconds", 300.0, tags, float64(1)).Return(nil),
+		mockStatsD.EXPECT().Gauge("anemometer.consecutive_failures", 2.0, tags, float64(1)).Return(nil),
+		mockStatsD.EXPECT().Gauge("anemometer.run_interval_seconds", 600.0, tags, float64(1)).Return(nil),
+		mockStatsD.EXPECT().Gauge("anemometer.consecutive_failures", 0.0, tags, float64(1)).Return(nil),
+		mockStatsD.EXPECT().Gauge("anemometer.run_interval_seconds", 300.0, tags, float64(1)).Return(nil),
+	)
+
+	monitor := &Monitor{
+		sink:              sink.NewStatsdWithClient(mockStatsD),
+		name:              "broken",
+		tags:              []string{"team:a"},
+		sleepDuration:     300,
+		maxFailureBackoff: time.Hour,
+	}
+
+	// Healthy runs send nothing
+	monitor.recordRun(nil)
+
+	monitor.recordRun(errors.New("relation \"orders\" does not exist"))
+	monitor.recordRun(errors.New("relation \"orders\" does not exist"))
+	assert.Equal(t, 2, monitor.consecutiveFailures)
+
+	monitor.recordRun(nil)
+	assert.Equal(t, 0, monitor.consecutiveFailures)
+
+	monitor.recordRun(nil)
+}
+
+func TestMonitorRunOnceReturnsRunErrors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStatsD := mock_statsd.NewMockClientInterface(ctrl)
+	mockStatsD.EXPECT().Gauge("anemometer.error", 1.0, []string{"name:dropped-table", "error_class:permanent"}, float64(1)).Return(nil)
+
+	databaseConn, err := createDBConn("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	defer databaseConn.Close()
+
+	monitor := &Monitor{
+		databaseConn: databaseConn,
+		sink:         sink.NewStatsdWithClient(mockStatsD),
+		name:         "dropped-table",
+		metric:       "app.test.dropped-table",
+		metricType:   "gauge",
+		sql:          "SELECT COUNT(*) AS metric FROM dropped_table",
+	}
+
+	assert.Error(t, monitor.runOnce(false))
+}
@@ -0,0 +1,58 @@
+package monitor
+
+import (
+	"context"
+	"sync"
+
+	"github.com/simplifi/anemometer/pkg/anemometer/config"
+)
+
+// querySlots holds a semaphore for every database with a concurrency limit,
+// by config.DatabaseConfig.Key, so that monitors sharing a database share it
+var querySlots = struct {
+	sync.Mutex
+	databases map[string]chan struct{}
+}{databases: make(map[string]chan struct{})}
+
+// databaseQuerySlots returns the semaphore limiting queries to the database,
+// or nil if it has no max_concurrent_queries
+func databaseQuerySlots(databaseConfig config.DatabaseConfig) chan struct{} {
+	if databaseConfig.MaxConcurrentQueries <= 0 {
+		return nil
+	}
+
+	querySlots.Lock()
+	defer querySlots.Unlock()
+
+	key := databaseConfig.Key()
+	slots, ok := querySlots.databases[key]
+	if !ok {
+		slots = make(chan struct{}, databaseConfig.MaxConcurrentQueries)
+		querySlots.databases[key] = slots
+	}
+
+	return slots
+}
+
+// acquireQuerySlot waits until the database has a free query slot
+func (m *Monitor) acquireQuerySlot(ctx context.Context) error {
+	if m.querySlots == nil {
+		return nil
+	}
+
+	select {
+	case m.querySlots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// releaseQuerySlot frees the slot taken by acquireQuerySlot
+func (m *Monitor) releaseQuerySlot() {
+	if m.querySlots == nil {
+		return
+	}
+
+	<-m.querySlots
+}
@@ -0,0 +1,91 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	mock_statsd "github.com/DataDog/datadog-go/v5/statsd/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/simplifi/anemometer/pkg/anemometer/config"
+	"github.com/simplifi/anemometer/pkg/anemometer/sink"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDatabaseQuerySlots(t *testing.T) {
+	databaseConfig := config.DatabaseConfig{
+		Type:                 "postgres",
+		Host:                 "slots.example.com",
+		MaxConcurrentQueries: 2,
+	}
+
+	slots := databaseQuerySlots(databaseConfig)
+	assert.Equal(t, 2, cap(slots))
+	assert.True(t, slots == databaseQuerySlots(databaseConfig))
+
+	databaseConfig.Host = "other.example.com"
+	assert.False(t, slots == databaseQuerySlots(databaseConfig))
+
+	databaseConfig.MaxConcurrentQueries = 0
+	assert.Nil(t, databaseQuerySlots(databaseConfig))
+}
+
+func TestMonitorQueryWaitsForSlot(t *testing.T) {
+	databaseConn, err := createDBConn("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	defer databaseConn.Close()
+
+	slots := make(chan struct{}, 1)
+	first := &Monitor{databaseConn: databaseConn, name: "first", sql: "SELECT 1 AS metric", querySlots: slots}
+	second := &Monitor{databaseConn: databaseConn, name: "second", sql: "SELECT 1 AS metric", querySlots: slots}
+
+	rows, release, err := first.query(context.Background())
+	assert.NoError(t, err)
+
+	// The only slot is taken, so the second monitor gives up waiting
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = second.query(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	rows.Close()
+	release()
+	assert.Equal(t, 0, len(slots))
+
+	rows, release, err = second.query(context.Background())
+	assert.NoError(t, err)
+	rows.Close()
+	release()
+	assert.Equal(t, 0, len(slots))
+}
+
+func TestMonitorRunOnceFreesSlotBeforeSending(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	databaseConn, err := createDBConn("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	defer databaseConn.Close()
+
+	slots := make(chan struct{}, 1)
+
+	// The slot is free by the time the rows are sent to the sink
+	mockStatsD := mock_statsd.NewMockClientInterface(ctrl)
+	mockStatsD.EXPECT().GaugeWithTimestamp("test.metric", float64(1), gomock.Any(), float64(1), gomock.Any()).
+		DoAndReturn(func(string, float64, []string, float64, time.Time) error {
+			assert.Equal(t, 0, len(slots))
+			return nil
+		}).Times(2)
+
+	monitor := &Monitor{
+		databaseConn: databaseConn,
+		sink:         sink.NewStatsdWithClient(mockStatsD),
+		name:         "slots",
+		metric:       "test.metric",
+		metricType:   "gauge",
+		sql:          "SELECT 1 AS metric UNION ALL SELECT 1 AS metric",
+		querySlots:   slots,
+	}
+
+	assert.NoError(t, monitor.runOnce(false))
+}
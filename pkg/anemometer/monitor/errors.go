@@ -0,0 +1,177 @@
+package monitor
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	mssql "github.com/microsoft/go-mssqldb"
+	vertigo "github.com/vertica/vertica-sql-go"
+)
+
+// Error classes, sent as the error_class tag of anemometer.error
+const (
+	// errorTransient errors, such as a dropped connection or a deadlock, may
+	// succeed if the query is run again
+	errorTransient = "transient"
+	// errorPermanent errors, such as a syntax error or a missing column, fail
+	// the same way every time
+	errorPermanent = "permanent"
+)
+
+// transientSQLStates are the SQLSTATE codes, or their first two characters for
+// whole classes, that Postgres and Vertica return for transient errors
+var transientSQLStates = []string{
+	"08",    // connection exception
+	"40001", // serialization failure
+	"40P01", // deadlock detected
+	"53",    // insufficient resources, such as too many connections
+	"57P01", // admin shutdown
+	"57P02", // crash shutdown
+	"57P03", // cannot connect now
+	"55P03", // lock not available
+}
+
+// transientMySQLErrors are MySQL and MariaDB error numbers for transient errors
+var transientMySQLErrors = map[uint16]bool{
+	1040: true, // too many connections
+	1205: true, // lock wait timeout
+	1213: true, // deadlock
+	1927: true, // connection killed
+}
+
+// transientSQLServerErrors are SQL Server and Azure SQL error numbers for
+// transient errors
+var transientSQLServerErrors = map[int32]bool{
+	1205:  true, // deadlock victim
+	10928: true, // resource limit reached
+	10929: true, // resource limit reached
+	40197: true, // service error, retry
+	40501: true, // service busy
+	40613: true, // database unavailable
+	49918: true, // not enough resources
+	49919: true, // too many operations
+	49920: true, // too many operations
+}
+
+// transientClickHouseErrors are ClickHouse exception codes for transient errors
+var transientClickHouseErrors = map[int32]bool{
+	159: true, // timeout exceeded
+	202: true, // too many simultaneous queries
+	203: true, // no free connection
+	209: true, // socket timeout
+	210: true, // network error
+}
+
+// transientMessages catch transient errors from drivers that don't return
+// codes, or that wrap them in plain errors
+var transientMessages = []string{
+	"bad connection",
+	"broken pipe",
+	"connection refused",
+	"connection reset",
+	"i/o timeout",
+	"too many connections",
+}
+
+// classifyError returns errorTransient for errors worth retrying and
+// errorPermanent for everything else
+func classifyError(err error) string {
+	if err == nil {
+		return errorPermanent
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return classifySQLState(string(pqErr.Code))
+	}
+
+	var verticaErr *vertigo.VError
+	if errors.As(err, &verticaErr) {
+		return classifySQLState(verticaErr.SQLState)
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return transientIf(transientMySQLErrors[mysqlErr.Number])
+	}
+
+	var mssqlErr mssql.Error
+	if errors.As(err, &mssqlErr) {
+		return transientIf(transientSQLServerErrors[mssqlErr.Number])
+	}
+
+	var clickhouseErr *clickhouse.Exception
+	if errors.As(err, &clickhouseErr) {
+		return transientIf(transientClickHouseErrors[clickhouseErr.Code])
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, mysql.ErrInvalidConn),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.EPIPE),
+		errors.As(err, &netErr):
+		return errorTransient
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, transient := range transientMessages {
+		if strings.Contains(message, transient) {
+			return errorTransient
+		}
+	}
+
+	return errorPermanent
+}
+
+func classifySQLState(state string) string {
+	for _, transient := range transientSQLStates {
+		if strings.HasPrefix(state, transient) {
+			return errorTransient
+		}
+	}
+
+	return errorPermanent
+}
+
+func transientIf(transient bool) string {
+	if transient {
+		return errorTransient
+	}
+
+	return errorPermanent
+}
+
+// retry calls fn until it succeeds, fails with a permanent error, or has been
+// retried maxRetries times, waiting backoff before the first retry and
+// doubling it up to maxBackoff before each one after
+func (m *Monitor) retry(fn func() error) error {
+	backoff := m.retryBackoff
+
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= m.maxRetries || classifyError(err) != errorTransient {
+			return err
+		}
+
+		log.Printf("WARN: [%s] Retrying in %v after transient error (%d/%d): %v",
+			m.name, backoff, attempt+1, m.maxRetries, err)
+		time.Sleep(backoff)
+
+		backoff *= 2
+		if backoff > m.maxRetryBackoff {
+			backoff = m.maxRetryBackoff
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package monitor
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	mssql "github.com/microsoft/go-mssqldb"
+	"github.com/stretchr/testify/assert"
+	vertigo "github.com/vertica/vertica-sql-go"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{name: "postgres_serialization_failure", err: &pq.Error{Code: "40001"}, expected: errorTransient},
+		{name: "postgres_too_many_connections", err: &pq.Error{Code: "53300"}, expected: errorTransient},
+		{name: "postgres_undefined_table", err: &pq.Error{Code: "42P01"}, expected: errorPermanent},
+		{name: "vertica_connection_failure", err: &vertigo.VError{SQLState: "08006"}, expected: errorTransient},
+		{name: "vertica_syntax_error", err: &vertigo.VError{SQLState: "42601"}, expected: errorPermanent},
+		{name: "mysql_deadlock", err: &mysql.MySQLError{Number: 1213}, expected: errorTransient},
+		{name: "mysql_unknown_column", err: &mysql.MySQLError{Number: 1054}, expected: errorPermanent},
+		{name: "mysql_invalid_connection", err: mysql.ErrInvalidConn, expected: errorTransient},
+		{name: "sqlserver_deadlock", err: mssql.Error{Number: 1205}, expected: errorTransient},
+		{name: "sqlserver_invalid_object", err: mssql.Error{Number: 208}, expected: errorPermanent},
+		{name: "clickhouse_too_many_queries", err: &clickhouse.Exception{Code: 202}, expected: errorTransient},
+		{name: "clickhouse_unknown_table", err: &clickhouse.Exception{Code: 60}, expected: errorPermanent},
+		{name: "bad_connection", err: driver.ErrBadConn, expected: errorTransient},
+		{name: "wrapped_connection_reset", err: fmt.Errorf("read: %w", syscall.ECONNRESET), expected: errorTransient},
+		{name: "network_error", err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("no route to host")}, expected: errorTransient},
+		{name: "message", err: errors.New("googleapi: Error 503: too many connections"), expected: errorTransient},
+		{name: "conversion_error", err: errors.New("no metric column found"), expected: errorPermanent},
+		{name: "nil", err: nil, expected: errorPermanent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, classifyError(tt.err))
+		})
+	}
+}
+
+func TestMonitorRetry(t *testing.T) {
+	tests := []struct {
+		name          string
+		errs          []error
+		expectedCalls int
+		expectedErr   bool
+	}{
+		{
+			name:          "succeeds",
+			expectedCalls: 1,
+		},
+		{
+			name:          "recovers_from_transient_errors",
+			errs:          []error{driver.ErrBadConn, &pq.Error{Code: "40001"}},
+			expectedCalls: 3,
+		},
+		{
+			name:          "gives_up_after_max_retries",
+			errs:          []error{driver.ErrBadConn, driver.ErrBadConn, driver.ErrBadConn, driver.ErrBadConn},
+			expectedCalls: 4,
+			expectedErr:   true,
+		},
+		{
+			name:          "does_not_retry_permanent_errors",
+			errs:          []error{&pq.Error{Code: "42P01"}},
+			expectedCalls: 1,
+			expectedErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			monitor := &Monitor{
+				name:            tt.name,
+				maxRetries:      3,
+				retryBackoff:    time.Millisecond,
+				maxRetryBackoff: 2 * time.Millisecond,
+			}
+
+			calls := 0
+			err := monitor.retry(func() error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+
+			assert.Equal(t, tt.expectedCalls, calls)
+			if tt.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
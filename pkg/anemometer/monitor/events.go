@@ -0,0 +1,117 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/simplifi/anemometer/pkg/anemometer/sink"
+)
+
+// eventLimiter is a token bucket limiting how many events a monitor sends
+// over time. It is only used from the monitor's own goroutine.
+type eventLimiter struct {
+	// perSecond is the rate tokens are added at, up to burst
+	perSecond float64
+	burst     float64
+	tokens    float64
+	last      time.Time
+}
+
+// newEventLimiter creates a limiter allowing perMinute events per minute,
+// starting full. It returns nil, allowing everything, when perMinute is 0.
+func newEventLimiter(perMinute float64, burst int) *eventLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &eventLimiter{
+		perSecond: perMinute / 60,
+		burst:     float64(burst),
+		tokens:    float64(burst),
+	}
+}
+
+// allow reports whether an event may be sent now, using up a token if so
+func (l *eventLimiter) allow(now time.Time) bool {
+	if l == nil {
+		return true
+	}
+
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.perSecond
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+
+	l.tokens--
+	return true
+}
+
+// allowEvent reports whether the row's event may be sent, counting it as
+// suppressed for this run if not. Events are counted as sent by processRow,
+// once they have been.
+func (m *Monitor) allowEvent(state *runState) bool {
+	if m.eventConfig.MaxEventsPerRun > 0 && state.eventsSent >= m.eventConfig.MaxEventsPerRun {
+		state.eventsSuppressed++
+		return false
+	}
+
+	if !m.eventLimiter.allow(time.Now()) {
+		state.eventsSuppressed++
+		return false
+	}
+
+	return true
+}
+
+// reportSuppressedEvents sends a single event summarizing the rows whose
+// events were suppressed this run, along with a metric counting them
+func (m *Monitor) reportSuppressedEvents(state *runState) {
+	if state.eventsSuppressed == 0 {
+		return
+	}
+
+	log.Printf("WARN: [%s] Suppressed %d event(s) over the event limits", m.name, state.eventsSuppressed)
+
+	m.sink.SendMetric(sink.Metric{
+		Monitor:    m.name,
+		Name:       "anemometer.events_suppressed",
+		Type:       "gauge",
+		Value:      float64(state.eventsSuppressed),
+		Tags:       append([]string{fmt.Sprintf("name:%s", m.name)}, m.tags...),
+		SampleRate: 1,
+	})
+
+	alertType, err := getEventAlertType(m.eventConfig.AlertType)
+	if err != nil {
+		alertType = "info"
+	}
+
+	tags := append(m.staticTags(), m.eventConfig.Tags...)
+
+	err = m.sink.SendEvent(sink.Event{
+		Monitor: m.name,
+		Title:   fmt.Sprintf("%s: %d additional rows suppressed", m.name, state.eventsSuppressed),
+		Text: fmt.Sprintf("%d event(s) were sent and %d suppressed by the monitor's event limits in the run started at %s.",
+			state.eventsSent, state.eventsSuppressed, state.started.Format(time.RFC3339)),
+		AlertType:      alertType,
+		Priority:       "normal",
+		SourceTypeName: m.eventConfig.SourceTypeName,
+		AggregationKey: fmt.Sprintf("anemometer-events-suppressed:%s", m.name),
+		Tags:           tags,
+	})
+	if err != nil {
+		log.Printf("ERROR: [%s] %v", m.name, err)
+	}
+}
@@ -0,0 +1,154 @@
+package monitor
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	mock_statsd "github.com/DataDog/datadog-go/v5/statsd/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/simplifi/anemometer/pkg/anemometer/config"
+	"github.com/simplifi/anemometer/pkg/anemometer/sink"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventLimiterAllow(t *testing.T) {
+	// 30 events per minute is one every two seconds, with bursts of 2
+	limiter := newEventLimiter(30, 2)
+	start := time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)
+
+	steps := []struct {
+		offset   time.Duration
+		expected bool
+	}{
+		{offset: 0, expected: true},
+		{offset: 0, expected: true},
+		{offset: 0, expected: false},
+		{offset: time.Second, expected: false},
+		{offset: 2 * time.Second, expected: true},
+		{offset: 2 * time.Second, expected: false},
+		// A long pause only refills up to the burst
+		{offset: time.Hour, expected: true},
+		{offset: time.Hour, expected: true},
+		{offset: time.Hour, expected: false},
+	}
+
+	for i, step := range steps {
+		assert.Equal(t, step.expected, limiter.allow(start.Add(step.offset)), "step %d", i)
+	}
+
+	// No rate limit allows everything
+	var unlimited *eventLimiter
+	assert.Nil(t, newEventLimiter(0, 0))
+	assert.True(t, unlimited.allow(start))
+}
+
+// eventTitleMatcher matches events by title prefix
+type eventTitleMatcher struct {
+	prefix string
+}
+
+func (m eventTitleMatcher) Matches(value interface{}) bool {
+	event, ok := value.(*statsd.Event)
+	return ok && strings.HasPrefix(event.Title, m.prefix)
+}
+
+func (m eventTitleMatcher) String() string {
+	return "event title starting with " + m.prefix
+}
+
+func TestMonitorMaxEventsPerRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStatsD := mock_statsd.NewMockClientInterface(ctrl)
+	mockStatsD.EXPECT().GaugeWithTimestamp("test.metric", gomock.Any(), gomock.Any(), float64(1), gomock.Any()).Return(nil).Times(5)
+	mockStatsD.EXPECT().Event(eventTitleMatcher{prefix: "Row "}).Return(nil).Times(2)
+	mockStatsD.EXPECT().Gauge("anemometer.events_suppressed", 3.0, []string{"name:runaway"}, float64(1)).Return(nil)
+	mockStatsD.EXPECT().Event(statsdEventMatcher{
+		expected: statsd.Event{
+			Title:          "runaway: 3 additional rows suppressed",
+			AggregationKey: "anemometer-events-suppressed:runaway",
+			Priority:       statsd.Normal,
+			SourceTypeName: "anemometer",
+			AlertType:      statsd.Warning,
+			Tags:           []string{"alert_type:runaway"},
+		},
+		ignoreText: true,
+	}).Return(nil)
+
+	databaseConn, err := createDBConn("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	defer databaseConn.Close()
+
+	monitor := &Monitor{
+		databaseConn: databaseConn,
+		sink:         sink.NewStatsdWithClient(mockStatsD),
+		name:         "runaway",
+		metric:       "test.metric",
+		metricType:   "gauge",
+		eventConfig: config.EventConfig{
+			Enabled:         true,
+			TitleColumn:     "event_title",
+			AlertType:       "warning",
+			SourceTypeName:  "anemometer",
+			Tags:            []string{"alert_type:runaway"},
+			MaxEventsPerRun: 2,
+		},
+		sql: `
+			SELECT 1 AS metric, 'Row 1' AS event_title
+			UNION ALL SELECT 2, 'Row 2'
+			UNION ALL SELECT 3, 'Row 3'
+			UNION ALL SELECT 4, 'Row 4'
+			UNION ALL SELECT 5, 'Row 5'
+		`,
+	}
+
+	monitor.runOnce(false)
+}
+
+func TestMonitorMaxEventsPerRunIgnoresFailedSends(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStatsD := mock_statsd.NewMockClientInterface(ctrl)
+	mockStatsD.EXPECT().GaugeWithTimestamp("test.metric", gomock.Any(), gomock.Any(), float64(1), gomock.Any()).Return(nil).Times(4)
+	gomock.InOrder(
+		mockStatsD.EXPECT().Event(eventTitleMatcher{prefix: "Row 1"}).Return(fmt.Errorf("event send failed")),
+		mockStatsD.EXPECT().Event(eventTitleMatcher{prefix: "Row 2"}).Return(nil),
+		mockStatsD.EXPECT().Event(eventTitleMatcher{prefix: "Row 3"}).Return(nil),
+	)
+	mockStatsD.EXPECT().Gauge("anemometer.error", 1.0, []string{"name:flaky", "error_class:permanent"}, float64(1)).Return(nil)
+	mockStatsD.EXPECT().Gauge("anemometer.events_suppressed", 1.0, []string{"name:flaky"}, float64(1)).Return(nil)
+	mockStatsD.EXPECT().Event(eventTitleMatcher{prefix: "flaky: 1 additional rows suppressed"}).Return(nil)
+
+	databaseConn, err := createDBConn("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	defer databaseConn.Close()
+
+	// The failed send doesn't use up one of the two events allowed per run
+	monitor := &Monitor{
+		databaseConn: databaseConn,
+		sink:         sink.NewStatsdWithClient(mockStatsD),
+		name:         "flaky",
+		metric:       "test.metric",
+		metricType:   "gauge",
+		eventConfig: config.EventConfig{
+			Enabled:         true,
+			TitleColumn:     "event_title",
+			AlertType:       "warning",
+			SourceTypeName:  "anemometer",
+			MaxEventsPerRun: 2,
+		},
+		sql: `
+			SELECT 1 AS metric, 'Row 1' AS event_title
+			UNION ALL SELECT 2, 'Row 2'
+			UNION ALL SELECT 3, 'Row 3'
+			UNION ALL SELECT 4, 'Row 4'
+		`,
+	}
+
+	monitor.runOnce(false)
+}
@@ -1,16 +1,26 @@
 package monitor
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
+	"math/big"
+	"math/rand"
+	"sort"
+	"strconv"
 	"strings"
+	"text/template"
 	"time"
 
-	"github.com/DataDog/datadog-go/v5/statsd"
-	_ "github.com/lib/pq"           // Postgres driver
-	_ "github.com/mattn/go-sqlite3" // SQLite driver
+	_ "github.com/ClickHouse/clickhouse-go/v2" // ClickHouse driver
+	"github.com/go-sql-driver/mysql"           // MySQL and MariaDB driver
+	_ "github.com/lib/pq"                      // Postgres driver
+	_ "github.com/mattn/go-sqlite3"            // SQLite driver
+	_ "github.com/microsoft/go-mssqldb"        // SQL Server driver
+	"github.com/microsoft/go-mssqldb/azuread"  // SQL Server driver with Azure AD authentication
 	"github.com/simplifi/anemometer/pkg/anemometer/config"
+	"github.com/simplifi/anemometer/pkg/anemometer/sink"
 	_ "github.com/vertica/vertica-sql-go" // Vertica driver
 	_ "github.com/viant/bigquery"         // BigQuery driver
 )
@@ -18,19 +28,45 @@ import (
 // Monitor runs a query and pushes results to DataDog as metrics/tags
 type Monitor struct {
 	databaseConn  *sql.DB
-	statsdClient  statsd.ClientInterface
+	sink          sink.Sink
 	name          string
 	sleepDuration int
 	metric        string
 	metricType    string
-	eventConfig   config.EventConfig
+	sampleRate    float64
+	tags          []string
+	tagColumns    []string
+	tagAliases    map[string]string
+	tagFormatter  tagFormatter
+	// maxTagCombinations caps the distinct metric tag sets sent per run
+	maxTagCombinations int
+	eventConfig        config.EventConfig
+	// Parsed from the event config, nil when it has no templates
+	eventTitleTemplate *template.Template
+	eventTextTemplate  *template.Template
+	// eventLimiter rate limits events across runs, nil when unlimited
+	eventLimiter *eventLimiter
 	// Computed once per monitor because it is used for every returned row.
 	metricExcludedColumns map[string]struct{}
 	sql                   string
+	sessionSQL            []string
+	// readOnly runs the query in a read-only transaction
+	readOnly bool
+	// Transient query errors are retried maxRetries times within a run
+	maxRetries      int
+	retryBackoff    time.Duration
+	maxRetryBackoff time.Duration
+	// consecutiveFailures is the number of runs in a row that failed, which
+	// stretches the sleep between runs up to maxFailureBackoff
+	consecutiveFailures int
+	maxFailureBackoff   time.Duration
+	// querySlots limits concurrent queries to the database, nil when unlimited
+	querySlots  chan struct{}
+	startJitter time.Duration
 }
 
-// New Monitor, pass in the MonitorConfig
-func New(statsdConfig config.StatsdConfig, monitorConfig config.MonitorConfig) (*Monitor, error) {
+// New Monitor, pass in the MonitorConfig and the Sink its results are sent to
+func New(monitorConfig config.MonitorConfig, sink sink.Sink) (*Monitor, error) {
 	if monitorConfig.EventConfig.Enabled {
 		if _, err := getEventAlertType(monitorConfig.EventConfig.AlertType); err != nil {
 			return nil, err
@@ -40,36 +76,96 @@ func New(statsdConfig config.StatsdConfig, monitorConfig config.MonitorConfig) (
 		}
 	}
 
-	databaseConn, err := createDBConn(monitorConfig.DatabaseConfig.Type, monitorConfig.DatabaseConfig.URI)
+	eventTitleTemplate, err := newEventTemplate("title", monitorConfig.EventConfig.TitleTemplate)
 	if err != nil {
 		return nil, err
 	}
 
-	statsdClient, err := createStatsdClient(
-		statsdConfig.Address,
-		statsdConfig.Tags,
-	)
+	eventTextTemplate, err := newEventTemplate("text", monitorConfig.EventConfig.TextTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	dsn, err := databaseDSN(monitorConfig.DatabaseConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	databaseConn, err := createDBConn(monitorConfig.DatabaseConfig.Type, dsn)
 	if err != nil {
 		return nil, err
 	}
 
 	monitor := Monitor{
 		databaseConn:          databaseConn,
-		statsdClient:          statsdClient,
+		sink:                  sink,
 		name:                  monitorConfig.Name,
 		sleepDuration:         monitorConfig.SleepDuration,
-		metric:                monitorConfig.Metric,
+		metric:                metricName(monitorConfig.Namespace, monitorConfig.Metric),
 		metricType:            monitorConfig.MetricType,
+		sampleRate:            monitorConfig.SampleRate,
+		tags:                  monitorConfig.Tags,
+		tagColumns:            monitorConfig.TagColumns,
+		tagAliases:            monitorConfig.TagAliases,
+		tagFormatter:          newTagFormatter(monitorConfig.TagNormalization),
+		maxTagCombinations:    monitorConfig.MaxTagCombinations,
 		eventConfig:           monitorConfig.EventConfig,
-		metricExcludedColumns: newMetricExcludedColumns(monitorConfig.EventConfig),
+		eventTitleTemplate:    eventTitleTemplate,
+		eventTextTemplate:     eventTextTemplate,
+		eventLimiter:          newEventLimiter(monitorConfig.EventConfig.RateLimit, monitorConfig.EventConfig.RateLimitBurst),
+		metricExcludedColumns: newMetricExcludedColumns(monitorConfig.EventConfig, monitorConfig.ExcludeColumns),
 		sql:                   monitorConfig.SQL,
+		sessionSQL:            monitorConfig.SessionSQL,
+		readOnly:              monitorConfig.DatabaseConfig.UsesReadOnlyTransaction(),
+		maxRetries:            maxRetries(monitorConfig),
+		retryBackoff:          monitorConfig.RetryBackoff,
+		maxRetryBackoff:       monitorConfig.MaxRetryBackoff,
+		maxFailureBackoff:     monitorConfig.MaxFailureBackoff,
+		querySlots:            databaseQuerySlots(monitorConfig.DatabaseConfig),
+		startJitter:           monitorConfig.StartJitter,
+	}
+
+	if !monitorConfig.DatabaseConfig.EnforcesReadOnly() {
+		log.Printf("WARN: [%s] %s cannot enforce read-only queries, use a database user that can only read",
+			monitor.name, monitorConfig.DatabaseConfig.Type)
 	}
 
 	return &monitor, nil
 }
 
+// maxRetries returns the configured retries, or none if they weren't set
+func maxRetries(monitorConfig config.MonitorConfig) int {
+	if monitorConfig.MaxRetries == nil {
+		return 0
+	}
+
+	return *monitorConfig.MaxRetries
+}
+
+// metricName prefixes the metric with the monitor's namespace, if it has one
+func metricName(namespace string, metric string) string {
+	if namespace == "" {
+		return metric
+	}
+
+	return strings.TrimSuffix(namespace, ".") + "." + metric
+}
+
 func createDBConn(dbType string, dbURI string) (*sql.DB, error) {
-	conn, err := sql.Open(dbType, dbURI)
+	driverName := dbType
+
+	switch dbType {
+	case "mysql":
+		var err error
+		dbURI, err = mysqlDSN(dbURI)
+		if err != nil {
+			return nil, err
+		}
+	case "sqlserver":
+		driverName = sqlserverDriverName(dbURI)
+	}
+
+	conn, err := sql.Open(driverName, dbURI)
 	if err != nil {
 		return nil, err
 	}
@@ -82,63 +178,120 @@ func createDBConn(dbType string, dbURI string) (*sql.DB, error) {
 	return conn, nil
 }
 
-func createStatsdClient(address string, tags []string) (statsd.ClientInterface, error) {
-	client, err := statsd.New(
-		address,
-		statsd.WithTags(tags),
-	)
+// mysqlDSN turns on parseTime, so DATETIME and TIMESTAMP columns are scanned
+// as time.Time rather than as bytes, unless the DSN already sets it
+func mysqlDSN(dsn string) (string, error) {
+	if strings.Contains(dsn, "parseTime=") {
+		return dsn, nil
+	}
+
+	mysqlConfig, err := mysql.ParseDSN(dsn)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
+	mysqlConfig.ParseTime = true
 
-	return client, nil
+	return mysqlConfig.FormatDSN(), nil
+}
+
+// sqlserverDriverName picks the Azure AD driver when the connection string
+// sets a fedauth method, as the plain driver ignores it
+func sqlserverDriverName(dsn string) string {
+	if strings.Contains(strings.ToLower(dsn), "fedauth=") {
+		return azuread.DriverName
+	}
+
+	return "sqlserver"
 }
 
 // sendMetric sends the appropriate metric type to Datadog based on the configured metric type
 func (m *Monitor) sendMetric(rowMap map[string]interface{}, tags []string, debug bool) error {
+	metric := sink.Metric{
+		Monitor:    m.name,
+		Name:       m.metric,
+		Type:       m.metricType,
+		Tags:       tags,
+		SampleRate: m.getSampleRate(),
+	}
+
+	// Sets count unique values, so any value (including strings) is allowed
+	if m.metricType == "set" {
+		metricString, err := getMetricString(rowMap)
+		if err != nil {
+			return err
+		}
+		metric.SetValue = metricString
+
+		if debug {
+			log.Printf("DEBUG: [%s] Publishing %s metric - Name: %s, Value: %v, Tags: %v",
+				m.name, m.metricType, m.metric, metricString, tags)
+		}
+
+		return m.sink.SendMetric(metric)
+	}
+
 	metricFloat, err := getMetricFloat64(rowMap)
 	if err != nil {
 		return err
 	}
+	metric.Value = metricFloat
 
 	timestamp, err := getTimestamp(rowMap)
 	if err != nil {
 		return err
 	}
 
+	// Only gauges and counts can be sent with an explicit timestamp
+	switch m.metricType {
+	case "gauge", "count":
+		metric.Timestamp = timestamp
+	}
+
 	if debug {
 		log.Printf("DEBUG: [%s] Publishing %s metric - Name: %s, Value: %v, Tags: %v",
 			m.name, m.metricType, m.metric, metricFloat, tags)
 	}
 
-	switch m.metricType {
-	case "count":
-		return m.statsdClient.CountWithTimestamp(m.metric, int64(metricFloat), tags, 1, timestamp)
-	case "histogram":
-		return m.statsdClient.Histogram(m.metric, metricFloat, tags, 1)
-	case "distribution":
-		return m.statsdClient.Distribution(m.metric, metricFloat, tags, 1)
-	case "gauge":
-		return m.statsdClient.GaugeWithTimestamp(m.metric, metricFloat, tags, 1, timestamp)
-	default:
-		return fmt.Errorf("unknown metric type: %s", m.metricType)
+	return m.sink.SendMetric(metric)
+}
+
+// getSampleRate returns the monitor's sample rate, defaulting to sending every
+// row
+func (m *Monitor) getSampleRate() float64 {
+	if m.sampleRate <= 0 {
+		return 1
 	}
+
+	return m.sampleRate
 }
 
-// sendEvent sends a Datadog event built from the configured event columns.
-func (m *Monitor) sendEvent(rowMap map[string]interface{}, tags []string, debug bool) error {
+// sendEvent sends a Datadog event built from the configured event templates
+// or columns.
+func (m *Monitor) sendEvent(rowMap map[string]interface{}, tags []string, state *runState, debug bool) error {
 	if !m.eventConfig.Enabled {
 		return nil
 	}
 
-	title, err := getEventField(rowMap, m.eventConfig.TitleColumn, m.eventConfig.Title, m.name, true)
+	title, err := m.renderEventTemplate(m.eventTitleTemplate, rowMap, state)
 	if err != nil {
-		return err
+		return fmt.Errorf("event title template: %w", err)
+	}
+	if title == "" {
+		title, err = getEventField(rowMap, m.eventConfig.TitleColumn, m.eventConfig.Title, m.name, true)
+		if err != nil {
+			return err
+		}
 	}
 
-	text, err := getEventField(rowMap, m.eventConfig.TextColumn, m.eventConfig.Text, "", false)
+	text, err := m.renderEventTemplate(m.eventTextTemplate, rowMap, state)
 	if err != nil {
-		return err
+		return fmt.Errorf("event text template: %w", err)
+	}
+	if text == "" {
+		text, err = getEventField(rowMap, m.eventConfig.TextColumn, m.eventConfig.Text, "", false)
+		if err != nil {
+			return err
+		}
 	}
 
 	alertType, err := getEventAlertType(m.eventConfig.AlertType)
@@ -151,11 +304,15 @@ func (m *Monitor) sendEvent(rowMap map[string]interface{}, tags []string, debug
 		return err
 	}
 
-	event := statsd.NewEvent(title, text)
-	event.AlertType = alertType
-	event.Priority = priority
-	event.SourceTypeName = m.eventConfig.SourceTypeName
-	event.Tags = tags
+	event := sink.Event{
+		Monitor:        m.name,
+		Title:          title,
+		Text:           text,
+		AlertType:      alertType,
+		Priority:       priority,
+		SourceTypeName: m.eventConfig.SourceTypeName,
+		Tags:           tags,
+	}
 
 	aggregationKey, err := getEventField(rowMap, m.eventConfig.AggregationKeyColumn, m.eventConfig.AggregationKey, "", false)
 	if err != nil {
@@ -174,97 +331,162 @@ func (m *Monitor) sendEvent(rowMap map[string]interface{}, tags []string, debug
 			m.name, event.Title, event.AlertType, event.Priority, event.Tags)
 	}
 
-	return m.statsdClient.Event(event)
+	return m.sink.SendEvent(event)
 }
 
 // Start the Monitor
 func (m *Monitor) Start(debug bool) {
+	if m.startJitter > 0 {
+		jitter := time.Duration(rand.Int63n(int64(m.startJitter)))
+		log.Printf("INFO: [%s] Delaying the first run by %v", m.name, jitter)
+		time.Sleep(jitter)
+	}
+
 	for {
-		log.Printf("INFO: [%s] Sleeping for %d seconds", m.name, m.sleepDuration)
-		time.Sleep(time.Duration(m.sleepDuration) * time.Second)
+		interval := m.nextInterval()
+		if m.consecutiveFailures > 1 {
+			log.Printf("INFO: [%s] Sleeping for %v, backing off after %d consecutive failures",
+				m.name, interval, m.consecutiveFailures)
+		} else {
+			log.Printf("INFO: [%s] Sleeping for %d seconds", m.name, m.sleepDuration)
+		}
+		time.Sleep(interval)
 
-		m.runOnce(debug)
+		m.recordRun(m.runOnce(debug))
 	}
 }
 
-func (m *Monitor) runOnce(debug bool) {
-	rows, err := m.databaseConn.Query(m.sql)
+// runOnce runs the query and sends its results. The error, already logged and
+// sent, is returned when the run failed as a whole rather than for a row.
+func (m *Monitor) runOnce(debug bool) error {
+	var rows *sql.Rows
+	var release func()
+	err := m.retry(func() error {
+		var err error
+		rows, release, err = m.query(context.Background())
+		return err
+	})
 	if err != nil {
 		log.Printf("ERROR: [%s] %v", m.name, err)
-		sendErrorMetric(m.statsdClient, m.name)
-		return
+		sendErrorMetric(m.sink, m.name, m.tags, err)
+		return err
 	}
-	defer func() {
-		if err := rows.Close(); err != nil {
-			log.Printf("ERROR: [%s] %v", m.name, err)
-			sendErrorMetric(m.statsdClient, m.name)
+
+	// The connection and query slot are freed before anything is sent, so
+	// slow sinks don't keep other monitors off the database
+	results, err := readRows(rows)
+	release()
+
+	state := newRunState()
+	defer m.reportDroppedRows(state)
+	defer m.reportSuppressedEvents(state)
+
+	for _, result := range results {
+		if result.err != nil {
+			log.Printf("ERROR: [%s] %v", m.name, result.err)
+			sendErrorMetric(m.sink, m.name, m.tags, result.err)
+			continue
 		}
-	}()
 
-	cols, err := rows.Columns()
+		m.processRow(result.row, result.columns, state, debug)
+	}
+
 	if err != nil {
 		log.Printf("ERROR: [%s] %v", m.name, err)
-		sendErrorMetric(m.statsdClient, m.name)
-		return
+		sendErrorMetric(m.sink, m.name, m.tags, err)
+		return err
 	}
 
-	// Iterate on the resulting rows
+	return nil
+}
+
+// rowResult is a single row read by readRows, or the error converting it
+type rowResult struct {
+	row     map[string]interface{}
+	columns []string
+	err     error
+}
+
+// readRows reads and closes the rows. The rows read before an error are
+// returned with it.
+func readRows(rows *sql.Rows) ([]rowResult, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		rows.Close()
+		return nil, err
+	}
+
+	var results []rowResult
 	for rows.Next() {
 		// Convert our result row into a map
-		rowMap, err := rowsToMap(cols, rows)
-		if err != nil {
-			log.Printf("ERROR: [%s] %v", m.name, err)
-			sendErrorMetric(m.statsdClient, m.name)
-			continue
-		}
-
-		m.processRow(rowMap, debug)
+		rowMap, columns, err := rowsToMap(cols, rows)
+		results = append(results, rowResult{row: rowMap, columns: columns, err: err})
 	}
 
-	if err := rows.Err(); err != nil {
-		log.Printf("ERROR: [%s] %v", m.name, err)
-		sendErrorMetric(m.statsdClient, m.name)
+	err = rows.Err()
+	if closeErr := rows.Close(); err == nil {
+		err = closeErr
 	}
+
+	return results, err
 }
 
-func (m *Monitor) processRow(rowMap map[string]interface{}, debug bool) {
-	// Send the metric to Datadog using the configured metric type.
-	if err := m.sendMetric(rowMap, m.getMetricTags(rowMap), debug); err != nil {
-		log.Printf("ERROR: [%s] %v", m.name, err)
-		sendErrorMetric(m.statsdClient, m.name)
+func (m *Monitor) processRow(rowMap map[string]interface{}, columns []string, state *runState, debug bool) {
+	// Send the metric to Datadog using the configured metric type. Rows over
+	// the tag combination limit only lose their metric, not their event.
+	metricTags, err := m.getMetricTags(rowMap, columns)
+	if err == nil && !state.allowTags(metricTags, m.maxTagCombinations) {
+		if debug {
+			log.Printf("DEBUG: [%s] Dropping metric over the tag combination limit - Tags: %v", m.name, metricTags)
+		}
+	} else {
+		if err == nil {
+			err = m.sendMetric(rowMap, metricTags, debug)
+		}
+		if err != nil {
+			log.Printf("ERROR: [%s] %v", m.name, err)
+			sendErrorMetric(m.sink, m.name, m.tags, err)
+		}
 	}
 
-	if !m.eventConfig.Enabled {
+	if !m.eventConfig.Enabled || !m.allowEvent(state) {
 		return
 	}
 
 	eventTags, err := m.getEventTags(rowMap)
 	if err != nil {
 		log.Printf("ERROR: [%s] %v", m.name, err)
-		sendErrorMetric(m.statsdClient, m.name)
+		sendErrorMetric(m.sink, m.name, m.tags, err)
 		return
 	}
 
-	if err = m.sendEvent(rowMap, eventTags, debug); err != nil {
+	if err = m.sendEvent(rowMap, eventTags, state, debug); err != nil {
 		log.Printf("ERROR: [%s] %v", m.name, err)
-		sendErrorMetric(m.statsdClient, m.name)
+		sendErrorMetric(m.sink, m.name, m.tags, err)
+		return
 	}
+
+	state.eventsSent++
 }
 
-// Sends an error metric to StatsD
-func sendErrorMetric(statsdClient statsd.ClientInterface, name string) {
-	statsdClient.Gauge(
-		"anemometer.error",
-		1,
-		[]string{fmt.Sprintf("name:%s", name)},
-		1,
-	)
+// Sends an error metric to the sink
+func sendErrorMetric(s sink.Sink, name string, tags []string, err error) {
+	s.SendMetric(sink.Metric{
+		Monitor:    name,
+		Name:       "anemometer.error",
+		Type:       "gauge",
+		Value:      1,
+		Tags:       append([]string{fmt.Sprintf("name:%s", name), "error_class:" + classifyError(err)}, tags...),
+		SampleRate: 1,
+	})
 }
 
 // We cannot use a struct for query results since our queries can change based
 // on the provided configuration.
 // This function converts the rows into a map so it will be easier to work with.
-func rowsToMap(cols []string, rows *sql.Rows) (map[string]interface{}, error) {
+// It also returns the column names in the order the query returned them (with
+// any duplicate names removed) so tags can be built in a stable order.
+func rowsToMap(cols []string, rows *sql.Rows) (map[string]interface{}, []string, error) {
 	// Create a slice of interface{}'s to represent each column,
 	// and a second slice to contain pointers to each item in the columns slice.
 	columns := make([]interface{}, len(cols))
@@ -275,51 +497,83 @@ func rowsToMap(cols []string, rows *sql.Rows) (map[string]interface{}, error) {
 
 	// Scan the result into the column pointers...
 	if err := rows.Scan(columnPointers...); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Create our map, and retrieve the value for each column from the pointers slice,
 	// storing it in the map with the name of the column as the key.
 	m := make(map[string]interface{})
+	order := make([]string, 0, len(cols))
 	for i, colName := range cols {
+		if _, ok := m[colName]; !ok {
+			order = append(order, colName)
+		}
+
 		val := columnPointers[i].(*interface{})
 		m[colName] = *val
 	}
 
-	return m, nil
+	return m, order, nil
 }
 
 // Function to aggregate tag columns
 // Assume that any column not named "metric" or "timestamp" is a tag
-func getTags(results map[string]interface{}) []string {
-	return getTagsExcluding(results, reservedMetricColumns())
+func getTags(results map[string]interface{}, columns []string) []string {
+	return getTagsExcluding(results, columns, reservedMetricColumns(), nil, tagFormatter{})
 }
 
-func (m *Monitor) getMetricTags(results map[string]interface{}) []string {
+// getMetricTags builds the metric tags from the configured tag columns, or
+// from every column that isn't excluded when no tag columns are configured
+func (m *Monitor) getMetricTags(results map[string]interface{}, columns []string) ([]string, error) {
+	tags := m.staticTags()
+
+	if len(m.tagColumns) > 0 {
+		for _, column := range m.tagColumns {
+			value, ok := results[column]
+			if !ok {
+				return nil, fmt.Errorf("metric tag column not found: %s", column)
+			}
+
+			tags = append(tags, m.tagFormatter.format(tagKey(column, m.tagAliases), value))
+		}
+
+		return tags, nil
+	}
+
 	excludedColumns := m.metricExcludedColumns
 	if excludedColumns == nil {
-		excludedColumns = newMetricExcludedColumns(m.eventConfig)
+		excludedColumns = newMetricExcludedColumns(m.eventConfig, nil)
 	}
 
-	return getTagsExcluding(results, excludedColumns)
+	return append(tags, getTagsExcluding(results, columns, excludedColumns, m.tagAliases, m.tagFormatter)...), nil
 }
 
 func (m *Monitor) getEventTags(results map[string]interface{}) ([]string, error) {
-	tags := make([]string, 0, len(m.eventConfig.Tags)+len(m.eventConfig.TagColumns))
+	tags := make([]string, 0, len(m.tags)+len(m.eventConfig.Tags)+len(m.eventConfig.TagColumns))
+	tags = append(tags, m.tags...)
 	tags = append(tags, m.eventConfig.Tags...)
 
 	for _, column := range m.eventConfig.TagColumns {
-		value, ok := getColumnString(results, column)
+		value, ok := results[column]
 		if !ok {
 			return nil, fmt.Errorf("event tag column not found: %s", column)
 		}
 
-		tags = append(tags, fmt.Sprintf("%v:%v", column, value))
+		tags = append(tags, m.tagFormatter.format(column, value))
 	}
 
 	return tags, nil
 }
 
+// staticTags returns a copy of the monitor's configured tags, safe to append to
+func (m *Monitor) staticTags() []string {
+	if len(m.tags) == 0 {
+		return nil
+	}
+
+	return append([]string(nil), m.tags...)
+}
+
 func reservedMetricColumns() map[string]struct{} {
 	return map[string]struct{}{
 		"metric":    {},
@@ -327,9 +581,13 @@ func reservedMetricColumns() map[string]struct{} {
 	}
 }
 
-func newMetricExcludedColumns(eventConfig config.EventConfig) map[string]struct{} {
+func newMetricExcludedColumns(eventConfig config.EventConfig, excludeColumns []string) map[string]struct{} {
 	excludedColumns := reservedMetricColumns()
 
+	for _, name := range excludeColumns {
+		excludedColumns[name] = struct{}{}
+	}
+
 	if eventConfig.Enabled {
 		for _, name := range eventMetricExcludedColumns(eventConfig) {
 			excludedColumns[name] = struct{}{}
@@ -361,22 +619,60 @@ func eventMetricExcludedColumns(eventConfig config.EventConfig) []string {
 	return columns
 }
 
-func getTagsExcluding(results map[string]interface{}, excludedColumns map[string]struct{}) []string {
+// getTagsExcluding builds tags from every column that isn't excluded, in the
+// order of columns
+func getTagsExcluding(results map[string]interface{}, columns []string, excludedColumns map[string]struct{}, aliases map[string]string, formatter tagFormatter) []string {
 	var tags []string
 
-	for name, value := range results {
+	for _, name := range orderedColumns(results, columns) {
+		value := results[name]
+
 		// Ignore the metric and timestamp columns, we only care about tags here
 		if _, ok := excludedColumns[name]; ok {
 			continue
 		}
 
 		// Aggregate all the tag columns
-		tags = append(tags, fmt.Sprintf("%v:%v", name, value))
+		tags = append(tags, formatter.format(tagKey(name, aliases), value))
 	}
 
 	return tags
 }
 
+// orderedColumns returns the columns of a row in query order. Rows built
+// without a column order fall back to sorting by name, so tags are still
+// deterministic.
+func orderedColumns(results map[string]interface{}, columns []string) []string {
+	if columns != nil {
+		return columns
+	}
+
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// tagKey returns the tag key for a column, using its alias if it has one.
+// The config loader lower-cases the alias keys, so columns from drivers that
+// keep their case are matched case-insensitively.
+func tagKey(column string, aliases map[string]string) string {
+	if alias, ok := aliases[column]; ok && alias != "" {
+		return alias
+	}
+
+	for name, alias := range aliases {
+		if alias != "" && strings.EqualFold(name, column) {
+			return alias
+		}
+	}
+
+	return column
+}
+
 func getEventField(results map[string]interface{}, column string, fallback string, defaultValue string, required bool) (string, error) {
 	if column != "" {
 		value, ok := getColumnString(results, column)
@@ -410,43 +706,48 @@ func getColumnString(results map[string]interface{}, column string) (string, boo
 		return "", false
 	}
 
+	return valueString(value), true
+}
+
+// valueString converts a column value to a string, with NULL as ""
+func valueString(value interface{}) string {
 	if value == nil {
-		return "", true
+		return ""
 	}
 
 	switch v := value.(type) {
 	case string:
-		return v, true
+		return v
 	case []byte:
-		return string(v), true
+		return string(v)
 	case time.Time:
-		return v.Format(time.RFC3339), true
+		return v.Format(time.RFC3339)
 	default:
-		return fmt.Sprintf("%v", v), true
+		return fmt.Sprintf("%v", v)
 	}
 }
 
-func getEventAlertType(alertType string) (statsd.EventAlertType, error) {
+func getEventAlertType(alertType string) (string, error) {
 	switch strings.ToLower(alertType) {
 	case "", "info":
-		return statsd.Info, nil
+		return "info", nil
 	case "error":
-		return statsd.Error, nil
+		return "error", nil
 	case "warning":
-		return statsd.Warning, nil
+		return "warning", nil
 	case "success":
-		return statsd.Success, nil
+		return "success", nil
 	default:
 		return "", fmt.Errorf("unknown event alert type: %s", alertType)
 	}
 }
 
-func getEventPriority(priority string) (statsd.EventPriority, error) {
+func getEventPriority(priority string) (string, error) {
 	switch strings.ToLower(priority) {
 	case "", "normal":
-		return statsd.Normal, nil
+		return "normal", nil
 	case "low":
-		return statsd.Low, nil
+		return "low", nil
 	default:
 		return "", fmt.Errorf("unknown event priority: %s", priority)
 	}
@@ -470,10 +771,42 @@ func getMetricFloat64(results map[string]interface{}) (float64, error) {
 			metric = float64(v)
 		case int64:
 			metric = float64(v)
+		case uint:
+			metric = float64(v)
+		case uint8:
+			metric = float64(v)
+		case uint16:
+			metric = float64(v)
+		case uint32:
+			metric = float64(v)
+		case uint64:
+			metric = float64(v)
 		case float32:
 			metric = float64(v)
 		case float64:
 			metric = v
+		case []byte:
+			// Drivers such as MySQL and SQL Server return DECIMAL and MONEY
+			// columns, and MySQL every column of a query without placeholders,
+			// as their text representation
+			parsed, err := strconv.ParseFloat(string(v), 64)
+			if err != nil {
+				return -1, fmt.Errorf("failed to convert metric column value: '%s'", v)
+			}
+			metric = parsed
+		case string:
+			// ClickHouse returns Decimal columns as strings
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return -1, fmt.Errorf("failed to convert metric column value: '%v'", val)
+			}
+			metric = parsed
+		case *big.Int:
+			// ClickHouse returns 128 and 256 bit integers as big.Int
+			if v == nil {
+				return -1, fmt.Errorf("failed to convert metric column value: NULL")
+			}
+			metric, _ = new(big.Float).SetInt(v).Float64()
 		case bool:
 			if v {
 				metric = 1
@@ -490,10 +823,30 @@ func getMetricFloat64(results map[string]interface{}) (float64, error) {
 	return metric, nil
 }
 
+// Function to pull the 'metric' column's value as a string, for set metrics
+// If the column is missing or NULL, this will return an error
+func getMetricString(results map[string]interface{}) (string, error) {
+	val, ok := results["metric"]
+	if !ok {
+		return "", fmt.Errorf("no metric column found")
+	}
+
+	if val == nil {
+		return "", fmt.Errorf("failed to convert metric column value: NULL")
+	}
+
+	return valueString(val), nil
+}
+
 // Function to pull the 'timestamp' column's value, convert, and return it as time.Time
 // If conversion isn't possible, or column is missing, this will return an error
 func getTimestamp(results map[string]interface{}) (time.Time, error) {
 	if val, ok := results["timestamp"]; ok {
+		// Text columns may be scanned as bytes, which are parsed like strings
+		if b, ok := val.([]byte); ok {
+			val = string(b)
+		}
+
 		switch v := val.(type) {
 		case time.Time:
 			return v, nil
@@ -512,6 +865,10 @@ func getTimestamp(results map[string]interface{}) (time.Time, error) {
 			return time.Unix(int64(v), 0).UTC(), nil
 		case int:
 			return time.Unix(int64(v), 0).UTC(), nil
+		case uint32:
+			return time.Unix(int64(v), 0).UTC(), nil
+		case uint64:
+			return time.Unix(int64(v), 0).UTC(), nil
 		case float64:
 			return time.Unix(int64(v), 0).UTC(), nil
 		case sql.NullTime:
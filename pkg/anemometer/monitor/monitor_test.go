@@ -3,6 +3,9 @@ package monitor
 import (
 	"database/sql"
 	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -12,14 +15,11 @@ import (
 	"github.com/golang/mock/gomock"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/simplifi/anemometer/pkg/anemometer/config"
+	"github.com/simplifi/anemometer/pkg/anemometer/sink"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestMonitorNew(t *testing.T) {
-	testStatsdConfig := config.StatsdConfig{
-		Address: "localhost:8125",
-	}
-
 	testDatabaseConfig := config.DatabaseConfig{
 		Type: "sqlite3",
 		URI:  ":memory:",
@@ -33,7 +33,7 @@ func TestMonitorNew(t *testing.T) {
 		SQL:            "SELECT 100 AS metric, 'tag' AS my_tag",
 	}
 
-	monitor, err := New(testStatsdConfig, testMonitorCfg)
+	monitor, err := New(testMonitorCfg, sink.NewStatsdWithClient(mock_statsd.NewMockClientInterface(gomock.NewController(t))))
 
 	assert.NoError(t, err)
 	assert.NotNil(t, monitor)
@@ -81,6 +81,22 @@ func TestMonitorIntegration(t *testing.T) {
 				m.EXPECT().Distribution("app.test.distribution-metric", 75.25, []string{"category:baseline"}, float64(1)).Return(nil)
 			},
 		},
+		{
+			name:       "set-metric",
+			metricType: "set",
+			sqlQuery:   "SELECT 'user-1234' AS metric, 'checkout' AS page",
+			setupMock: func(m *mock_statsd.MockClientInterface) {
+				m.EXPECT().Set("app.test.set-metric", "user-1234", []string{"page:checkout"}, float64(1)).Return(nil)
+			},
+		},
+		{
+			name:       "timing-metric",
+			metricType: "timing",
+			sqlQuery:   "SELECT 1250.5 AS metric, 'etl' AS job",
+			setupMock: func(m *mock_statsd.MockClientInterface) {
+				m.EXPECT().TimeInMilliseconds("app.test.timing-metric", 1250.5, []string{"job:etl"}, float64(1)).Return(nil)
+			},
+		},
 		{
 			name:       "count-with-explicit-timestamp",
 			metricType: "count",
@@ -129,7 +145,7 @@ func TestMonitorIntegration(t *testing.T) {
 
 			monitor := &Monitor{
 				databaseConn:  databaseConn,
-				statsdClient:  mockStatsD,
+				sink:          sink.NewStatsdWithClient(mockStatsD),
 				name:          tc.name,
 				sleepDuration: 100,
 				metric:        "app.test." + tc.name,
@@ -150,7 +166,7 @@ func TestMonitorIntegrationWithEvent(t *testing.T) {
 	mockStatsD.EXPECT().GaugeWithTimestamp(
 		"postgres.long_running_query",
 		1.0,
-		stringSliceMatcher{expected: []string{"database_name:analytics", "duration_bucket:2h_plus"}},
+		[]string{"database_name:analytics", "duration_bucket:2h_plus"},
 		float64(1),
 		gomock.Any(),
 	).Return(nil)
@@ -176,7 +192,7 @@ func TestMonitorIntegrationWithEvent(t *testing.T) {
 
 	monitor := &Monitor{
 		databaseConn:  databaseConn,
-		statsdClient:  mockStatsD,
+		sink:          sink.NewStatsdWithClient(mockStatsD),
 		name:          "postgres-long-running-queries",
 		sleepDuration: 100,
 		metric:        "postgres.long_running_query",
@@ -213,7 +229,7 @@ func TestProcessRowMetricFailureDoesNotSkipEvent(t *testing.T) {
 
 	mockStatsD := mock_statsd.NewMockClientInterface(ctrl)
 	mockStatsD.EXPECT().GaugeWithTimestamp("postgres.long_running_query", 1.0, []string{"database_name:analytics"}, float64(1), gomock.Any()).Return(fmt.Errorf("metric send failed"))
-	mockStatsD.EXPECT().Gauge("anemometer.error", 1.0, []string{"name:postgres-long-running-queries"}, float64(1)).Return(nil)
+	mockStatsD.EXPECT().Gauge("anemometer.error", 1.0, []string{"name:postgres-long-running-queries", "error_class:permanent"}, float64(1)).Return(nil)
 	mockStatsD.EXPECT().Event(statsdEventMatcher{
 		expected: statsd.Event{
 			Title:          "Long running Postgres query",
@@ -227,10 +243,10 @@ func TestProcessRowMetricFailureDoesNotSkipEvent(t *testing.T) {
 	}).Return(nil)
 
 	monitor := &Monitor{
-		statsdClient: mockStatsD,
-		name:         "postgres-long-running-queries",
-		metric:       "postgres.long_running_query",
-		metricType:   "gauge",
+		sink:       sink.NewStatsdWithClient(mockStatsD),
+		name:       "postgres-long-running-queries",
+		metric:     "postgres.long_running_query",
+		metricType: "gauge",
 		eventConfig: config.EventConfig{
 			Enabled:              true,
 			Title:                "Long running Postgres query",
@@ -248,7 +264,55 @@ func TestProcessRowMetricFailureDoesNotSkipEvent(t *testing.T) {
 		"database_name":         "analytics",
 		"event_text":            "Database: analytics",
 		"event_aggregation_key": "postgres-long-running-query:analytics:41273",
-	}, false)
+	}, nil, newRunState(), false)
+}
+
+func TestProcessRowOverTagLimitDoesNotSkipEvent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStatsD := mock_statsd.NewMockClientInterface(ctrl)
+	mockStatsD.EXPECT().GaugeWithTimestamp("postgres.long_running_query", 1.0, []string{"database_name:analytics"}, float64(1), gomock.Any()).Return(nil)
+	for _, database := range []string{"analytics", "reporting"} {
+		mockStatsD.EXPECT().Event(statsdEventMatcher{
+			expected: statsd.Event{
+				Title:          "Long running Postgres query",
+				Text:           "Database: " + database,
+				Priority:       statsd.Normal,
+				SourceTypeName: "anemometer",
+				AlertType:      statsd.Warning,
+				Tags:           []string{"database_name:" + database},
+			},
+		}).Return(nil)
+	}
+
+	monitor := &Monitor{
+		sink:               sink.NewStatsdWithClient(mockStatsD),
+		name:               "postgres-long-running-queries",
+		metric:             "postgres.long_running_query",
+		metricType:         "gauge",
+		maxTagCombinations: 1,
+		eventConfig: config.EventConfig{
+			Enabled:        true,
+			Title:          "Long running Postgres query",
+			TextColumn:     "event_text",
+			AlertType:      "warning",
+			Priority:       "normal",
+			SourceTypeName: "anemometer",
+			TagColumns:     []string{"database_name"},
+		},
+	}
+
+	// The second row is over the limit, so only its metric is dropped
+	state := newRunState()
+	for _, database := range []string{"analytics", "reporting"} {
+		monitor.processRow(map[string]interface{}{
+			"metric":        1,
+			"database_name": database,
+			"event_text":    "Database: " + database,
+		}, nil, state, false)
+	}
+	assert.Equal(t, 1, state.droppedRows)
 }
 
 func TestMonitorNewRejectsInvalidEventConfig(t *testing.T) {
@@ -277,9 +341,9 @@ func TestMonitorNewRejectsInvalidEventConfig(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			monitor, err := New(config.StatsdConfig{}, config.MonitorConfig{
+			monitor, err := New(config.MonitorConfig{
 				EventConfig: tt.eventConfig,
-			})
+			}, nil)
 
 			assert.Nil(t, monitor)
 			assert.Error(t, err)
@@ -337,12 +401,42 @@ func TestGetMetricFloat64(t *testing.T) {
 			expected: 0.0,
 			hasError: true,
 		},
+		{
+			name:     "numeric_string_value",
+			input:    map[string]interface{}{"metric": "1234.50"},
+			expected: 1234.5,
+			hasError: false,
+		},
+		{
+			name:     "big_int_value",
+			input:    map[string]interface{}{"metric": new(big.Int).Lsh(big.NewInt(1), 70)},
+			expected: 1180591620717411303424.0,
+			hasError: false,
+		},
 		{
 			name:     "string_value_should_error",
 			input:    map[string]interface{}{"metric": "not_a_number"},
 			expected: 0.0,
 			hasError: true,
 		},
+		{
+			name:     "uint64_value",
+			input:    map[string]interface{}{"metric": uint64(18446744073709551615)},
+			expected: 18446744073709551615.0,
+			hasError: false,
+		},
+		{
+			name:     "bytes_decimal_value",
+			input:    map[string]interface{}{"metric": []byte("1234.50")},
+			expected: 1234.5,
+			hasError: false,
+		},
+		{
+			name:     "bytes_non_numeric_should_error",
+			input:    map[string]interface{}{"metric": []byte("not_a_number")},
+			expected: 0.0,
+			hasError: true,
+		},
 		{
 			name:     "negative_int",
 			input:    map[string]interface{}{"metric": -42},
@@ -413,7 +507,7 @@ func TestGetTags(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := getTags(tt.input)
+			result := getTags(tt.input, nil)
 			assert.ElementsMatch(t, tt.expected, result)
 		})
 	}
@@ -442,12 +536,121 @@ func TestGetMetricTagsWithEventConfig(t *testing.T) {
 		},
 	}
 
+	tags, err := monitor.getMetricTags(rowMap, nil)
+	assert.NoError(t, err)
 	assert.ElementsMatch(t, []string{
 		"database_name:analytics",
 		"duration_bucket:2h_plus",
 		"pid:41273",
 		"user_name:reporting_user",
-	}, monitor.getMetricTags(rowMap))
+	}, tags)
+}
+
+func TestGetMetricTagsColumnSelection(t *testing.T) {
+	rowMap := map[string]interface{}{
+		"metric":     1,
+		"timestamp":  "2023-12-25T10:30:00Z",
+		"datname":    "analytics",
+		"usename":    "reporting_user",
+		"sort_order": 3,
+	}
+
+	tests := []struct {
+		name           string
+		tagColumns     []string
+		excludeColumns []string
+		tagAliases     map[string]string
+		expected       []string
+		expectedErr    string
+	}{
+		{
+			name:     "all_columns",
+			expected: []string{"datname:analytics", "usename:reporting_user", "sort_order:3"},
+		},
+		{
+			name:       "allowlist",
+			tagColumns: []string{"datname", "usename"},
+			expected:   []string{"datname:analytics", "usename:reporting_user"},
+		},
+		{
+			name:           "denylist",
+			excludeColumns: []string{"sort_order"},
+			expected:       []string{"datname:analytics", "usename:reporting_user"},
+		},
+		{
+			name:           "aliases",
+			excludeColumns: []string{"sort_order"},
+			tagAliases:     map[string]string{"datname": "database_name", "usename": "user_name"},
+			expected:       []string{"database_name:analytics", "user_name:reporting_user"},
+		},
+		{
+			name:       "allowlist_with_aliases",
+			tagColumns: []string{"datname"},
+			tagAliases: map[string]string{"datname": "database_name"},
+			expected:   []string{"database_name:analytics"},
+		},
+		{
+			name:        "missing_allowlisted_column",
+			tagColumns:  []string{"datname", "client_addr"},
+			expectedErr: "metric tag column not found: client_addr",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			monitor := &Monitor{
+				tagColumns:            tt.tagColumns,
+				tagAliases:            tt.tagAliases,
+				metricExcludedColumns: newMetricExcludedColumns(config.EventConfig{}, tt.excludeColumns),
+			}
+
+			tags, err := monitor.getMetricTags(rowMap, []string{"metric", "timestamp", "datname", "usename", "sort_order"})
+			if tt.expectedErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, tags)
+		})
+	}
+}
+
+func TestGetMetricTagsAliasesFromConfig(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "anemometer.yml")
+	assert.NoError(t, os.WriteFile(configPath, []byte(`
+monitors:
+  - name: mixed-case-columns
+    database:
+      type: sqlite3
+      uri: ":memory:"
+    metric: users.count
+    sql: SELECT 1 AS metric, 'analytics' AS DatabaseName, 'reporting' AS UserName
+    tag_aliases:
+      DatabaseName: database_name
+      UserName: user_name
+`), 0644))
+
+	cfg, err := config.Read(configPath)
+	assert.NoError(t, err)
+
+	monitor, err := New(cfg.Monitors[0], sink.NewStatsdWithClient(mock_statsd.NewMockClientInterface(gomock.NewController(t))))
+	assert.NoError(t, err)
+
+	rows, err := monitor.databaseConn.Query(monitor.sql)
+	assert.NoError(t, err)
+	defer rows.Close()
+	columns, err := rows.Columns()
+	assert.NoError(t, err)
+	assert.True(t, rows.Next())
+
+	rowMap, columns, err := rowsToMap(columns, rows)
+	assert.NoError(t, err)
+
+	tags, err := monitor.getMetricTags(rowMap, columns)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"database_name:analytics", "user_name:reporting"}, tags)
 }
 
 func TestGetTimestamp(t *testing.T) {
@@ -463,6 +666,15 @@ func TestGetTimestamp(t *testing.T) {
 		hasError bool
 	}{
 		// String timestamp tests
+		{
+			name: "valid_rfc3339_timestamp_bytes",
+			input: map[string]interface{}{
+				"metric":    42,
+				"timestamp": []byte(expectedTimeString),
+			},
+			expected: expectedTime,
+			hasError: false,
+		},
 		{
 			name: "valid_rfc3339_timestamp_string",
 			input: map[string]interface{}{
@@ -561,6 +773,24 @@ func TestGetTimestamp(t *testing.T) {
 			expected: time.Unix(unixTimestamp, 0).UTC(),
 			hasError: false,
 		},
+		{
+			name: "unix_timestamp_uint32",
+			input: map[string]interface{}{
+				"metric":    42,
+				"timestamp": uint32(unixTimestamp),
+			},
+			expected: time.Unix(unixTimestamp, 0).UTC(),
+			hasError: false,
+		},
+		{
+			name: "unix_timestamp_uint64",
+			input: map[string]interface{}{
+				"metric":    42,
+				"timestamp": uint64(unixTimestamp),
+			},
+			expected: time.Unix(unixTimestamp, 0).UTC(),
+			hasError: false,
+		},
 		{
 			name: "unix_timestamp_float64",
 			input: map[string]interface{}{
@@ -646,6 +876,8 @@ func TestMetricTypeHandling(t *testing.T) {
 		{name: "count_type", metricType: "count", expectErr: false},
 		{name: "histogram_type", metricType: "histogram", expectErr: false},
 		{name: "distribution_type", metricType: "distribution", expectErr: false},
+		{name: "set_type", metricType: "set", expectErr: false},
+		{name: "timing_type", metricType: "timing", expectErr: false},
 		{name: "unknown_type", metricType: "unknown", expectErr: true},
 	}
 
@@ -668,14 +900,18 @@ func TestMetricTypeHandling(t *testing.T) {
 					mockStatsD.EXPECT().Histogram("test.metric", 42.0, []string{"environment:test"}, float64(1)).Return(nil)
 				case "distribution":
 					mockStatsD.EXPECT().Distribution("test.metric", 42.0, []string{"environment:test"}, float64(1)).Return(nil)
+				case "set":
+					mockStatsD.EXPECT().Set("test.metric", "42", []string{"environment:test"}, float64(1)).Return(nil)
+				case "timing":
+					mockStatsD.EXPECT().TimeInMilliseconds("test.metric", 42.0, []string{"environment:test"}, float64(1)).Return(nil)
 				}
 			}
 
 			monitor := &Monitor{
-				name:         "test",
-				metric:       "test.metric",
-				metricType:   tt.metricType,
-				statsdClient: mockStatsD,
+				name:       "test",
+				metric:     "test.metric",
+				metricType: tt.metricType,
+				sink:       sink.NewStatsdWithClient(mockStatsD),
 			}
 
 			rowMap := map[string]interface{}{
@@ -721,8 +957,8 @@ func TestEventTypeHandling(t *testing.T) {
 			}
 
 			monitor := &Monitor{
-				name:         "test-event-monitor",
-				statsdClient: mockStatsD,
+				name: "test-event-monitor",
+				sink: sink.NewStatsdWithClient(mockStatsD),
 				eventConfig: config.EventConfig{
 					Enabled:    true,
 					Title:      "Test event",
@@ -733,7 +969,7 @@ func TestEventTypeHandling(t *testing.T) {
 				},
 			}
 
-			err := monitor.sendEvent(map[string]interface{}{}, []string{}, false)
+			err := monitor.sendEvent(map[string]interface{}{}, []string{}, newRunState(), false)
 
 			if tt.expectErr {
 				assert.Error(t, err)
@@ -746,6 +982,8 @@ func TestEventTypeHandling(t *testing.T) {
 
 type statsdEventMatcher struct {
 	expected statsd.Event
+	// ignoreText skips comparing text that depends on when the test ran
+	ignoreText bool
 }
 
 func (m statsdEventMatcher) Matches(value interface{}) bool {
@@ -755,7 +993,7 @@ func (m statsdEventMatcher) Matches(value interface{}) bool {
 	}
 
 	return event.Title == m.expected.Title &&
-		event.Text == m.expected.Text &&
+		(m.ignoreText || event.Text == m.expected.Text) &&
 		event.Timestamp.Equal(m.expected.Timestamp) &&
 		event.Hostname == m.expected.Hostname &&
 		event.AggregationKey == m.expected.AggregationKey &&
@@ -769,34 +1007,158 @@ func (m statsdEventMatcher) String() string {
 	return fmt.Sprintf("matches statsd event %+v", m.expected)
 }
 
-type stringSliceMatcher struct {
-	expected []string
+func TestGetTagsColumnOrder(t *testing.T) {
+	results := map[string]interface{}{
+		"metric":  1,
+		"zone":    "b",
+		"app":     "web",
+		"cluster": "c1",
+	}
+
+	// Tags follow the query's column order
+	assert.Equal(t, []string{"zone:b", "app:web", "cluster:c1"},
+		getTags(results, []string{"zone", "metric", "app", "cluster"}))
+
+	// Without a column order they are sorted by column name
+	assert.Equal(t, []string{"app:web", "cluster:c1", "zone:b"}, getTags(results, nil))
 }
 
-func (m stringSliceMatcher) Matches(value interface{}) bool {
-	actual, ok := value.([]string)
-	if !ok {
-		return false
-	}
+func TestRowsToMapColumnOrder(t *testing.T) {
+	databaseConn, err := createDBConn("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	defer databaseConn.Close()
 
-	if len(actual) != len(m.expected) {
-		return false
+	rows, err := databaseConn.Query("SELECT 'b' AS zone, 1 AS metric, 'web' AS app, 'c1' AS zone")
+	assert.NoError(t, err)
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	assert.NoError(t, err)
+	assert.True(t, rows.Next())
+
+	rowMap, columns, err := rowsToMap(cols, rows)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"zone", "metric", "app"}, columns)
+	assert.Equal(t, "c1", rowMap["zone"])
+}
+
+func TestMonitorTagOrderIsStable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStatsD := mock_statsd.NewMockClientInterface(ctrl)
+	mockStatsD.EXPECT().GaugeWithTimestamp(
+		"test.metric",
+		1.0,
+		[]string{"zone:b", "app:web", "cluster:c1", "region:us-east"},
+		float64(1),
+		gomock.Any(),
+	).Return(nil).Times(20)
+
+	databaseConn, err := createDBConn("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	defer databaseConn.Close()
+
+	monitor := &Monitor{
+		databaseConn: databaseConn,
+		sink:         sink.NewStatsdWithClient(mockStatsD),
+		name:         "tag-order",
+		metric:       "test.metric",
+		metricType:   "gauge",
+		sql:          "SELECT 'b' AS zone, 'web' AS app, 1 AS metric, 'c1' AS cluster, 'us-east' AS region",
 	}
 
-	counts := make(map[string]int, len(m.expected))
-	for _, value := range m.expected {
-		counts[value]++
+	for i := 0; i < 20; i++ {
+		monitor.runOnce(false)
 	}
-	for _, value := range actual {
-		counts[value]--
-		if counts[value] < 0 {
-			return false
-		}
+}
+
+func TestGetMetricString(t *testing.T) {
+	value, err := getMetricString(map[string]interface{}{"metric": "user-1234"})
+	assert.NoError(t, err)
+	assert.Equal(t, "user-1234", value)
+
+	value, err = getMetricString(map[string]interface{}{"metric": []byte("user-5678")})
+	assert.NoError(t, err)
+	assert.Equal(t, "user-5678", value)
+
+	value, err = getMetricString(map[string]interface{}{"metric": int64(42)})
+	assert.NoError(t, err)
+	assert.Equal(t, "42", value)
+
+	_, err = getMetricString(map[string]interface{}{"metric": nil})
+	assert.Error(t, err)
+
+	_, err = getMetricString(map[string]interface{}{"other": "value"})
+	assert.Error(t, err)
+}
+
+func TestMonitorSampleRate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStatsD := mock_statsd.NewMockClientInterface(ctrl)
+	mockStatsD.EXPECT().Histogram("test.latency", 12.5, []string{"endpoint:/orders"}, 0.25).Return(nil)
+
+	monitor := &Monitor{
+		name:       "sampled",
+		metric:     "test.latency",
+		metricType: "histogram",
+		sampleRate: 0.25,
+		sink:       sink.NewStatsdWithClient(mockStatsD),
 	}
 
-	return true
+	err := monitor.sendMetric(map[string]interface{}{"metric": 12.5}, []string{"endpoint:/orders"}, false)
+	assert.NoError(t, err)
 }
 
-func (m stringSliceMatcher) String() string {
-	return fmt.Sprintf("matches string slice %v", m.expected)
+func TestSendEventTemplates(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	titleTemplate, err := newEventTemplate("title", `{{.Monitor}}: query {{.Row.pid}} running for {{duration .Row.seconds}}`)
+	assert.NoError(t, err)
+	textTemplate, err := newEventTemplate("text", `{{.Metric}}={{.Value}} at {{.RunTime.Format "15:04"}}
+{{.Row.query | truncate 20 | codeblock "sql"}}`)
+	assert.NoError(t, err)
+
+	mockStatsD := mock_statsd.NewMockClientInterface(ctrl)
+	mockStatsD.EXPECT().Event(statsdEventMatcher{
+		expected: statsd.Event{
+			Title:     "long-running-queries: query 41273 running for 2h0m5s",
+			Text:      "postgres.long_running_query=1 at 14:30\n```sql\nSELECT * FROM eve...\n```",
+			Priority:  statsd.Normal,
+			AlertType: statsd.Info,
+			Tags:      []string{},
+		},
+	}).Return(nil)
+
+	monitor := &Monitor{
+		sink:   sink.NewStatsdWithClient(mockStatsD),
+		name:   "long-running-queries",
+		metric: "postgres.long_running_query",
+		eventConfig: config.EventConfig{
+			Enabled:     true,
+			TitleColumn: "ignored",
+		},
+		eventTitleTemplate: titleTemplate,
+		eventTextTemplate:  textTemplate,
+	}
+
+	state := newRunState()
+	state.started = time.Date(2023, 11, 14, 14, 30, 0, 0, time.UTC)
+
+	err = monitor.sendEvent(map[string]interface{}{
+		"metric":  int64(1),
+		"pid":     []byte("41273"),
+		"seconds": 7205.2,
+		"query":   "SELECT * FROM events WHERE created_at > now()",
+		"ignored": "Not the title",
+	}, []string{}, state, false)
+	assert.NoError(t, err)
+
+	// Referencing a column the row doesn't have is an error
+	err = monitor.sendEvent(map[string]interface{}{"metric": int64(1)}, []string{}, state, false)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "event title template")
 }
@@ -0,0 +1,157 @@
+package monitor
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	mock_statsd "github.com/DataDog/datadog-go/v5/statsd/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/simplifi/anemometer/pkg/anemometer/config"
+	"github.com/simplifi/anemometer/pkg/anemometer/sink"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMysqlDSN(t *testing.T) {
+	tests := []struct {
+		name     string
+		dsn      string
+		expected string
+		hasError bool
+	}{
+		{
+			name:     "adds_parse_time",
+			dsn:      "anemometer:secret@tcp(localhost:3306)/app",
+			expected: "anemometer:secret@tcp(localhost:3306)/app?parseTime=true",
+		},
+		{
+			name:     "keeps_other_params",
+			dsn:      "anemometer:secret@tcp(localhost:3306)/app?timeout=5s",
+			expected: "anemometer:secret@tcp(localhost:3306)/app?parseTime=true&timeout=5s",
+		},
+		{
+			name:     "explicit_parse_time_is_kept",
+			dsn:      "anemometer:secret@tcp(localhost:3306)/app?parseTime=false",
+			expected: "anemometer:secret@tcp(localhost:3306)/app?parseTime=false",
+		},
+		{
+			name:     "invalid_dsn",
+			dsn:      "localhost:3306",
+			hasError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := mysqlDSN(tt.dsn)
+
+			if tt.hasError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+// Runs against a real server, such as one started with:
+//
+//	docker run -d -p 3306:3306 -e MYSQL_ROOT_PASSWORD=secret mysql:8
+//	ANEMOMETER_TEST_MYSQL_DSN='root:secret@tcp(localhost:3306)/' make test-unit
+func TestMonitorIntegrationMySQL(t *testing.T) {
+	dsn := os.Getenv("ANEMOMETER_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("ANEMOMETER_TEST_MYSQL_DSN is not set")
+	}
+
+	expectedTime := time.Date(2023, 12, 25, 10, 30, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name       string
+		metricType string
+		sqlQuery   string
+		setupMock  func(*mock_statsd.MockClientInterface)
+	}{
+		{
+			name:       "decimal-gauge",
+			metricType: "gauge",
+			sqlQuery:   "SELECT CAST(85.5 AS DECIMAL(10, 2)) AS metric, 'premium' AS tier",
+			setupMock: func(m *mock_statsd.MockClientInterface) {
+				m.EXPECT().GaugeWithTimestamp("app.test.decimal-gauge", 85.5, []string{"tier:premium"}, float64(1), gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name:       "unsigned-count-with-datetime",
+			metricType: "count",
+			sqlQuery:   "SELECT CAST(42 AS UNSIGNED) AS metric, CAST('2023-12-25 10:30:00' AS DATETIME) AS timestamp, 7 AS shard",
+			setupMock: func(m *mock_statsd.MockClientInterface) {
+				m.EXPECT().CountWithTimestamp("app.test.unsigned-count-with-datetime", int64(42), []string{"shard:7"}, float64(1), expectedTime).Return(nil)
+			},
+		},
+		{
+			name:       "varchar-set",
+			metricType: "set",
+			sqlQuery:   "SELECT CAST('user-1234' AS CHAR) AS metric, CAST('checkout' AS BINARY) AS page",
+			setupMock: func(m *mock_statsd.MockClientInterface) {
+				m.EXPECT().Set("app.test.varchar-set", "user-1234", []string{"page:checkout"}, float64(1)).Return(nil)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockStatsD := mock_statsd.NewMockClientInterface(ctrl)
+			tc.setupMock(mockStatsD)
+
+			databaseConn, err := createDBConn("mysql", dsn)
+			assert.NoError(t, err)
+			defer databaseConn.Close()
+
+			monitor := &Monitor{
+				databaseConn:  databaseConn,
+				sink:          sink.NewStatsdWithClient(mockStatsD),
+				name:          tc.name,
+				sleepDuration: 100,
+				metric:        "app.test." + tc.name,
+				metricType:    tc.metricType,
+				sql:           tc.sqlQuery,
+			}
+
+			monitor.runOnce(false)
+		})
+	}
+}
+
+func TestMonitorReadOnlyMySQL(t *testing.T) {
+	dsn := os.Getenv("ANEMOMETER_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("ANEMOMETER_TEST_MYSQL_DSN is not set")
+	}
+
+	databaseConn, err := createDBConn("mysql", dsn)
+	assert.NoError(t, err)
+	defer databaseConn.Close()
+
+	_, err = databaseConn.Exec("CREATE DATABASE IF NOT EXISTS anemometer_test")
+	assert.NoError(t, err)
+	_, err = databaseConn.Exec("CREATE TABLE IF NOT EXISTS anemometer_test.read_only (id INT)")
+	assert.NoError(t, err)
+
+	monitor := &Monitor{
+		databaseConn: databaseConn,
+		name:         "read-only",
+		readOnly:     config.DatabaseConfig{Type: "mysql"}.UsesReadOnlyTransaction(),
+		sessionSQL:   []string{"SET SESSION max_execution_time = 1000"},
+		sql:          "INSERT INTO anemometer_test.read_only VALUES (1)",
+	}
+
+	_, _, err = monitor.query(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "READ ONLY transaction")
+}
@@ -0,0 +1,81 @@
+package monitor
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+)
+
+// queryer is what the query runs on, a transaction or a connection
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// query runs the session statements and then the monitor's query on a single
+// connection, inside a read-only transaction when the driver supports one.
+// It waits for a query slot first, when the database limits them. The
+// returned func ends the transaction, releases the connection and frees the
+// slot, and must be called once the rows are closed. A connection that ran
+// session statements is closed rather than returned to the pool, so its
+// settings can't leak into later queries.
+func (m *Monitor) query(ctx context.Context) (*sql.Rows, func(), error) {
+	if err := m.acquireQuerySlot(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	conn, err := m.databaseConn.Conn(ctx)
+	if err != nil {
+		m.releaseQuerySlot()
+		return nil, nil, err
+	}
+
+	closeConn := conn.Close
+	if len(m.sessionSQL) > 0 {
+		closeConn = func() error {
+			// A driver.ErrBadConn from Raw makes the pool discard the connection
+			conn.Raw(func(interface{}) error {
+				return driver.ErrBadConn
+			})
+			return conn.Close()
+		}
+	}
+
+	var q queryer = conn
+	release := func() {
+		closeConn()
+		m.releaseQuerySlot()
+	}
+
+	if m.readOnly {
+		tx, err := conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			release()
+			return nil, nil, err
+		}
+
+		release = func() {
+			// Nothing can have been written, rolling back just ends it
+			tx.Rollback()
+			closeConn()
+			m.releaseQuerySlot()
+		}
+		q = tx
+	}
+
+	for _, statement := range m.sessionSQL {
+		if _, err := q.ExecContext(ctx, statement); err != nil {
+			release()
+			return nil, nil, fmt.Errorf("session_sql failed: %w", err)
+		}
+	}
+
+	rows, err := q.QueryContext(ctx, m.sql)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+
+	return rows, release, nil
+}
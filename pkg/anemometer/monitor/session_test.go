@@ -0,0 +1,96 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonitorQuerySessionSQL(t *testing.T) {
+	tests := []struct {
+		name     string
+		readOnly bool
+	}{
+		{name: "connection"},
+		{name: "transaction", readOnly: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			databaseConn, err := createDBConn("sqlite3", ":memory:")
+			assert.NoError(t, err)
+			defer databaseConn.Close()
+
+			// Temporary tables only exist on the connection that created them,
+			// so the query only finds it on the session statements' connection
+			monitor := &Monitor{
+				databaseConn: databaseConn,
+				name:         tt.name,
+				readOnly:     tt.readOnly,
+				sessionSQL: []string{
+					"CREATE TEMP TABLE session_settings (name TEXT, value TEXT)",
+					"INSERT INTO session_settings VALUES ('statement_timeout', '30s')",
+				},
+				sql: "SELECT value AS metric FROM session_settings WHERE name = 'statement_timeout'",
+			}
+
+			rows, release, err := monitor.query(context.Background())
+			assert.NoError(t, err)
+			defer release()
+			defer rows.Close()
+
+			assert.True(t, rows.Next())
+			var value string
+			assert.NoError(t, rows.Scan(&value))
+			assert.Equal(t, "30s", value)
+		})
+	}
+}
+
+func TestMonitorQuerySessionSQLError(t *testing.T) {
+	databaseConn, err := createDBConn("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	defer databaseConn.Close()
+
+	monitor := &Monitor{
+		databaseConn: databaseConn,
+		name:         "session-error",
+		readOnly:     true,
+		sessionSQL:   []string{"SET statement_timeout = '30s'"},
+		sql:          "SELECT 1 AS metric",
+	}
+
+	_, _, err = monitor.query(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "session_sql failed")
+
+	// The connection was released, so the pool can still be closed and used
+	assert.Equal(t, 0, databaseConn.Stats().InUse)
+}
+
+func TestMonitorQuerySessionSQLDiscardsConnection(t *testing.T) {
+	databaseConn, err := createDBConn("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	defer databaseConn.Close()
+
+	// Creating the table again would fail if the connection went back to the
+	// pool and was reused with the previous run's session
+	monitor := &Monitor{
+		databaseConn: databaseConn,
+		name:         "session-discard",
+		sessionSQL:   []string{"CREATE TEMP TABLE session_settings (name TEXT)"},
+		sql:          "SELECT COUNT(*) AS metric FROM session_settings",
+	}
+
+	for run := 0; run < 2; run++ {
+		rows, release, err := monitor.query(context.Background())
+		assert.NoError(t, err)
+		rows.Close()
+		release()
+	}
+
+	stats := databaseConn.Stats()
+	assert.Equal(t, 0, stats.Idle)
+	assert.Equal(t, 0, stats.InUse)
+}
@@ -0,0 +1,139 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/simplifi/anemometer/pkg/anemometer/config"
+	"github.com/simplifi/anemometer/pkg/anemometer/sink"
+)
+
+// tagFormatter turns SQL column values into tags that are safe to send in a
+// DogStatsD packet and follow Datadog's tag character rules
+type tagFormatter struct {
+	lowercase bool
+	maxLength int
+	nullValue string
+}
+
+func newTagFormatter(normalization config.TagNormalizationConfig) tagFormatter {
+	return tagFormatter{
+		lowercase: normalization.Lowercase,
+		maxLength: normalization.MaxLength,
+		nullValue: normalization.NullValue,
+	}
+}
+
+// format builds a "key:value" tag from a column name and its value
+func (f tagFormatter) format(key string, value interface{}) string {
+	tagValue := valueString(value)
+	if value == nil {
+		tagValue = f.nullValue
+		if tagValue == "" {
+			tagValue = config.DefaultTagNullValue
+		}
+	}
+
+	tag := sanitizeTag(key) + ":" + sanitizeTag(tagValue)
+	if f.lowercase {
+		tag = strings.ToLower(tag)
+	}
+
+	maxLength := f.maxLength
+	if maxLength <= 0 {
+		maxLength = config.DefaultTagMaxLength
+	}
+	if runes := []rune(tag); len(runes) > maxLength {
+		tag = string(runes[:maxLength])
+	}
+
+	return tag
+}
+
+// sanitizeTag replaces every run of characters Datadog doesn't allow in tags
+// with a single underscore. Commas, pipes and newlines would otherwise corrupt
+// the DogStatsD packet.
+func sanitizeTag(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+
+	replaced := false
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_-:./", r) {
+			b.WriteRune(r)
+			replaced = false
+			continue
+		}
+
+		if !replaced {
+			b.WriteRune('_')
+			replaced = true
+		}
+	}
+
+	return b.String()
+}
+
+// runState tracks what a single run of the monitor's query has sent
+type runState struct {
+	started          time.Time
+	tagCombinations  map[string]struct{}
+	droppedRows      int
+	eventsSent       int
+	eventsSuppressed int
+}
+
+func newRunState() *runState {
+	return &runState{
+		started:         time.Now(),
+		tagCombinations: make(map[string]struct{}),
+	}
+}
+
+// allowTags reports whether a row with these metric tags may be sent without
+// exceeding the monitor's limit of distinct tag combinations per run
+func (s *runState) allowTags(tags []string, maxCombinations int) bool {
+	if maxCombinations <= 0 {
+		return true
+	}
+
+	sorted := append([]string(nil), tags...)
+	sort.Strings(sorted)
+	key := strings.Join(sorted, ",")
+
+	if _, ok := s.tagCombinations[key]; ok {
+		return true
+	}
+
+	if len(s.tagCombinations) >= maxCombinations {
+		s.droppedRows++
+		return false
+	}
+
+	s.tagCombinations[key] = struct{}{}
+	return true
+}
+
+// reportDroppedRows logs and sends a metric for rows dropped by the
+// cardinality limit during a run
+func (m *Monitor) reportDroppedRows(state *runState) {
+	if state.droppedRows == 0 {
+		return
+	}
+
+	log.Printf("WARN: [%s] Dropped %d row(s) exceeding the limit of %d tag combinations",
+		m.name, state.droppedRows, m.maxTagCombinations)
+
+	m.sink.SendMetric(sink.Metric{
+		Monitor:    m.name,
+		Name:       "anemometer.dropped_rows",
+		Type:       "gauge",
+		Value:      float64(state.droppedRows),
+		Tags:       append([]string{fmt.Sprintf("name:%s", m.name)}, m.tags...),
+		SampleRate: 1,
+	})
+}
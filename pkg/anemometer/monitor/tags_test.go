@@ -0,0 +1,143 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	mock_statsd "github.com/DataDog/datadog-go/v5/statsd/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/simplifi/anemometer/pkg/anemometer/sink"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagFormatterFormat(t *testing.T) {
+	tests := []struct {
+		name      string
+		formatter tagFormatter
+		key       string
+		value     interface{}
+		expected  string
+	}{
+		{
+			name:     "plain_value",
+			key:      "environment",
+			value:    "production",
+			expected: "environment:production",
+		},
+		{
+			name:     "packet_breaking_characters",
+			key:      "query",
+			value:    "SELECT a, b | c\nFROM t",
+			expected: "query:SELECT_a_b_c_FROM_t",
+		},
+		{
+			name:     "allowed_punctuation_and_unicode",
+			key:      "path",
+			value:    "/var/lib:data-1.0_é",
+			expected: "path:/var/lib:data-1.0_é",
+		},
+		{
+			name:     "sanitized_key",
+			key:      "user name",
+			value:    "bob",
+			expected: "user_name:bob",
+		},
+		{
+			name:     "null_default_placeholder",
+			key:      "region",
+			value:    nil,
+			expected: "region:null",
+		},
+		{
+			name:      "null_custom_placeholder",
+			formatter: tagFormatter{nullValue: "none"},
+			key:       "region",
+			value:     nil,
+			expected:  "region:none",
+		},
+		{
+			name:     "bytes_value",
+			key:      "region",
+			value:    []byte("us-east"),
+			expected: "region:us-east",
+		},
+		{
+			name:     "time_value",
+			key:      "day",
+			value:    time.Date(2023, 12, 25, 0, 0, 0, 0, time.UTC),
+			expected: "day:2023-12-25T00:00:00Z",
+		},
+		{
+			name:      "lowercase",
+			formatter: tagFormatter{lowercase: true},
+			key:       "Region",
+			value:     "US-East",
+			expected:  "region:us-east",
+		},
+		{
+			name:      "max_length",
+			formatter: tagFormatter{maxLength: 10},
+			key:       "region",
+			value:     "us-east-1",
+			expected:  "region:us-",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.formatter.format(tt.key, tt.value))
+		})
+	}
+}
+
+func TestRunStateAllowTags(t *testing.T) {
+	state := newRunState()
+
+	assert.True(t, state.allowTags([]string{"a:1", "b:1"}, 2))
+	assert.True(t, state.allowTags([]string{"a:2"}, 2))
+	// Repeats of an existing combination are always allowed, in any order
+	assert.True(t, state.allowTags([]string{"b:1", "a:1"}, 2))
+	assert.False(t, state.allowTags([]string{"a:3"}, 2))
+	assert.False(t, state.allowTags([]string{"a:4"}, 2))
+	assert.Equal(t, 2, state.droppedRows)
+
+	// No limit configured
+	unlimited := newRunState()
+	for _, tag := range []string{"a:1", "a:2", "a:3"} {
+		assert.True(t, unlimited.allowTags([]string{tag}, 0))
+	}
+	assert.Equal(t, 0, unlimited.droppedRows)
+}
+
+func TestMonitorMaxTagCombinations(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStatsD := mock_statsd.NewMockClientInterface(ctrl)
+	mockStatsD.EXPECT().GaugeWithTimestamp("test.metric", 1.0, []string{"user_id:1"}, float64(1), gomock.Any()).Return(nil)
+	mockStatsD.EXPECT().GaugeWithTimestamp("test.metric", 2.0, []string{"user_id:2"}, float64(1), gomock.Any()).Return(nil)
+	mockStatsD.EXPECT().GaugeWithTimestamp("test.metric", 4.0, []string{"user_id:1"}, float64(1), gomock.Any()).Return(nil)
+	mockStatsD.EXPECT().Gauge("anemometer.dropped_rows", 2.0, []string{"name:high-cardinality"}, float64(1)).Return(nil)
+
+	databaseConn, err := createDBConn("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	defer databaseConn.Close()
+
+	monitor := &Monitor{
+		databaseConn:       databaseConn,
+		sink:               sink.NewStatsdWithClient(mockStatsD),
+		name:               "high-cardinality",
+		metric:             "test.metric",
+		metricType:         "gauge",
+		maxTagCombinations: 2,
+		sql: `
+			SELECT 1 AS metric, 1 AS user_id
+			UNION ALL SELECT 2, 2
+			UNION ALL SELECT 3, 3
+			UNION ALL SELECT 4, 1
+			UNION ALL SELECT 5, 5
+		`,
+	}
+
+	monitor.runOnce(false)
+}
@@ -0,0 +1,65 @@
+package monitor
+
+import (
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/simplifi/anemometer/pkg/anemometer/config"
+)
+
+// eventTemplateData is what event title and text templates are rendered with
+type eventTemplateData struct {
+	// Row holds every column of the row, with NULLs as "" and bytes as strings
+	Row map[string]interface{}
+	// Value is the row's metric column
+	Value   interface{}
+	Monitor string
+	Metric  string
+	// RunTime is when the monitor's current run started
+	RunTime time.Time
+}
+
+// newEventTemplate parses an event template, returning nil when there is none
+func newEventTemplate(name string, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+
+	return template.New(name).Funcs(config.TemplateFuncs).Option("missingkey=error").Parse(text)
+}
+
+// renderEventTemplate renders the template for the row, returning "" when
+// there is no template
+func (m *Monitor) renderEventTemplate(tmpl *template.Template, results map[string]interface{}, state *runState) (string, error) {
+	if tmpl == nil {
+		return "", nil
+	}
+
+	row := make(map[string]interface{}, len(results))
+	for column, value := range results {
+		switch v := value.(type) {
+		case nil:
+			row[column] = ""
+		case []byte:
+			row[column] = string(v)
+		default:
+			row[column] = v
+		}
+	}
+
+	data := eventTemplateData{
+		Row:     row,
+		Value:   row["metric"],
+		Monitor: m.name,
+		Metric:  m.metric,
+		RunTime: state.started,
+	}
+
+	var rendered strings.Builder
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return "", err
+	}
+
+	return rendered.String(), nil
+}
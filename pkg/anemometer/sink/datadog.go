@@ -0,0 +1,346 @@
+package sink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/simplifi/anemometer/pkg/anemometer/config"
+)
+
+// Metric types understood by the v2 series API
+const (
+	seriesTypeCount = 1
+	seriesTypeRate  = 2
+	seriesTypeGauge = 3
+)
+
+// DatadogAPI sends metrics and events straight to the Datadog HTTP API, for
+// hosts that have no Datadog agent. Metrics are buffered and sent in batches,
+// either when BatchSize metrics are waiting or every FlushInterval.
+// Histograms and timings are aggregated the way the agent aggregates them, so
+// they arrive as the same .avg, .count, .median, .max and .95percentile
+// metrics the statsd sink produces.
+type DatadogAPI struct {
+	config config.DatadogAPIConfig
+	poster *httpPoster
+
+	mu            sync.Mutex
+	series        []apiSeries
+	distributions []apiDistribution
+	histograms    map[string]*apiHistogram
+	// buffered counts every metric waiting for the next flush
+	buffered int
+
+	setWarning sync.Once
+	flush      chan struct{}
+	done       chan struct{}
+	wg         sync.WaitGroup
+}
+
+type apiPoint struct {
+	Timestamp int64   `json:"timestamp"`
+	Value     float64 `json:"value"`
+}
+
+type apiSeries struct {
+	Metric string     `json:"metric"`
+	Type   int        `json:"type"`
+	Points []apiPoint `json:"points"`
+	Tags   []string   `json:"tags,omitempty"`
+	// Interval is the number of seconds a rate is spread over
+	Interval int64 `json:"interval,omitempty"`
+}
+
+// apiHistogram collects the values of a histogram or timing with the same
+// name and tags between flushes
+type apiHistogram struct {
+	metric    string
+	tags      []string
+	timestamp int64
+	values    []float64
+	// count is the number of values before sampling
+	count float64
+}
+
+type apiDistribution struct {
+	Metric string `json:"metric"`
+	// Points are [timestamp, [values...]] pairs
+	Points [][]interface{} `json:"points"`
+	Tags   []string        `json:"tags,omitempty"`
+}
+
+type apiEvent struct {
+	Title          string   `json:"title"`
+	Text           string   `json:"text"`
+	DateHappened   int64    `json:"date_happened,omitempty"`
+	AlertType      string   `json:"alert_type,omitempty"`
+	Priority       string   `json:"priority,omitempty"`
+	SourceTypeName string   `json:"source_type_name,omitempty"`
+	AggregationKey string   `json:"aggregation_key,omitempty"`
+	Host           string   `json:"host,omitempty"`
+	Tags           []string `json:"tags,omitempty"`
+}
+
+// NewDatadogAPI creates a DatadogAPI sink from an already normalized config
+// and starts flushing it in the background
+func NewDatadogAPI(apiConfig config.DatadogAPIConfig) (*DatadogAPI, error) {
+	if apiConfig.APIKey == "" {
+		return nil, errors.New("datadog api key is not set")
+	}
+
+	d := &DatadogAPI{
+		config: apiConfig,
+		poster: newHTTPPoster(apiConfig.Timeout, map[string]string{
+			"Content-Type":     "application/json",
+			"Content-Encoding": "gzip",
+			"DD-API-KEY":       apiConfig.APIKey,
+		}, retryCount(apiConfig.MaxRetries), apiConfig.RetryBackoff),
+		flush: make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+
+	d.wg.Add(1)
+	go d.run()
+
+	return d, nil
+}
+
+// SendMetric buffers the metric until the next flush. Set metrics need the
+// agent to count unique values, so they are dropped.
+func (d *DatadogAPI) SendMetric(metric Metric) error {
+	if !sampled(metric) {
+		return nil
+	}
+
+	timestamp := metric.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	tags := d.tags(metric.Tags)
+
+	d.mu.Lock()
+	switch metric.Type {
+	case "gauge":
+		d.series = append(d.series, apiSeries{
+			Metric: metric.Name,
+			Type:   seriesTypeGauge,
+			Points: []apiPoint{{Timestamp: timestamp.Unix(), Value: metric.Value}},
+			Tags:   tags,
+		})
+	case "count":
+		d.series = append(d.series, apiSeries{
+			Metric: metric.Name,
+			Type:   seriesTypeCount,
+			Points: []apiPoint{{Timestamp: timestamp.Unix(), Value: scaledValue(metric)}},
+			Tags:   tags,
+		})
+	case "histogram", "timing":
+		key := metric.Name + "\x00" + strings.Join(tags, ",")
+		histogram, ok := d.histograms[key]
+		if !ok {
+			histogram = &apiHistogram{metric: metric.Name, tags: tags}
+			if d.histograms == nil {
+				d.histograms = make(map[string]*apiHistogram)
+			}
+			d.histograms[key] = histogram
+		}
+		if timestamp.Unix() > histogram.timestamp {
+			histogram.timestamp = timestamp.Unix()
+		}
+		histogram.values = append(histogram.values, metric.Value)
+		// Each sampled value stands for 1/rate values in the count
+		histogram.count += 1 / sampleRate(metric)
+	case "distribution":
+		d.distributions = append(d.distributions, apiDistribution{
+			Metric: metric.Name,
+			Points: [][]interface{}{{timestamp.Unix(), []float64{metric.Value}}},
+			Tags:   tags,
+		})
+	case "set":
+		d.mu.Unlock()
+		d.setWarning.Do(func() {
+			log.Printf("WARN: set metrics cannot be sent to the Datadog API, dropping %s", metric.Name)
+		})
+		return nil
+	default:
+		d.mu.Unlock()
+		return fmt.Errorf("unknown metric type: %s", metric.Type)
+	}
+	d.buffered++
+	full := d.buffered >= d.config.BatchSize
+	d.mu.Unlock()
+
+	if full {
+		select {
+		case d.flush <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// SendEvent posts the event to the events API
+func (d *DatadogAPI) SendEvent(event Event) error {
+	payload := apiEvent{
+		Title:          event.Title,
+		Text:           event.Text,
+		AlertType:      event.AlertType,
+		Priority:       event.Priority,
+		SourceTypeName: event.SourceTypeName,
+		AggregationKey: event.AggregationKey,
+		Host:           event.Hostname,
+		Tags:           d.tags(event.Tags),
+	}
+	if !event.Timestamp.IsZero() {
+		payload.DateHappened = event.Timestamp.Unix()
+	}
+
+	return d.post("/api/v1/events", payload)
+}
+
+// Flush sends every buffered metric
+func (d *DatadogAPI) Flush() error {
+	d.mu.Lock()
+	series, distributions := d.series, d.distributions
+	keys := make([]string, 0, len(d.histograms))
+	for key := range d.histograms {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		series = append(series, d.histograms[key].series(d.config.FlushInterval)...)
+	}
+	d.series, d.distributions, d.histograms, d.buffered = nil, nil, nil, 0
+	d.mu.Unlock()
+
+	var errs []error
+	if len(series) > 0 {
+		errs = append(errs, d.post("/api/v2/series", map[string]interface{}{"series": series}))
+	}
+	if len(distributions) > 0 {
+		errs = append(errs, d.post("/api/v1/distribution_points", map[string]interface{}{"series": distributions}))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Close stops the background flushing and sends whatever is still buffered
+func (d *DatadogAPI) Close() error {
+	close(d.done)
+	d.wg.Wait()
+
+	return d.Flush()
+}
+
+func (d *DatadogAPI) run() {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(d.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+		case <-d.flush:
+		}
+
+		if err := d.Flush(); err != nil {
+			log.Printf("ERROR: Failed to send metrics to the Datadog API: %v", err)
+		}
+	}
+}
+
+// series turns the histogram into the gauges the agent would send for it, and
+// a count spread as a rate over the flush interval
+func (h *apiHistogram) series(interval time.Duration) []apiSeries {
+	values := append([]float64{}, h.values...)
+	sort.Float64s(values)
+
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+
+	seconds := int64(interval / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	gauge := func(suffix string, value float64) apiSeries {
+		return apiSeries{
+			Metric: h.metric + suffix,
+			Type:   seriesTypeGauge,
+			Points: []apiPoint{{Timestamp: h.timestamp, Value: value}},
+			Tags:   h.tags,
+		}
+	}
+
+	return []apiSeries{
+		gauge(".max", values[len(values)-1]),
+		gauge(".median", percentile(values, 0.5)),
+		gauge(".avg", sum/float64(len(values))),
+		{
+			Metric:   h.metric + ".count",
+			Type:     seriesTypeRate,
+			Points:   []apiPoint{{Timestamp: h.timestamp, Value: h.count / float64(seconds)}},
+			Tags:     h.tags,
+			Interval: seconds,
+		},
+		gauge(".95percentile", percentile(values, 0.95)),
+	}
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(values []float64, p float64) float64 {
+	rank := int(math.Ceil(p*float64(len(values)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+
+	return values[rank]
+}
+
+// tags appends the configured global tags to the given tags
+func (d *DatadogAPI) tags(tags []string) []string {
+	if len(d.config.Tags) == 0 {
+		return tags
+	}
+
+	return append(append([]string{}, tags...), d.config.Tags...)
+}
+
+// post sends the payload as gzipped JSON
+func (d *DatadogAPI) post(path string, payload interface{}) error {
+	body, err := gzipJSON(payload)
+	if err != nil {
+		return err
+	}
+
+	return d.poster.post(d.config.URL+path, body)
+}
+
+func gzipJSON(payload interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(payload); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
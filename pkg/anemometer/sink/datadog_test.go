@@ -0,0 +1,252 @@
+package sink
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/simplifi/anemometer/pkg/anemometer/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDatadog records the decoded bodies posted to each API path
+type fakeDatadog struct {
+	mu       sync.Mutex
+	requests map[string][]map[string]interface{}
+	// failures is the number of requests to answer with a 503 first
+	failures int
+}
+
+func (f *fakeDatadog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("DD-API-KEY") != "test-key" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if f.failures > 0 {
+		f.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	zr, err := gzip.NewReader(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(zr).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if f.requests == nil {
+		f.requests = make(map[string][]map[string]interface{})
+	}
+	f.requests[r.URL.Path] = append(f.requests[r.URL.Path], body)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (f *fakeDatadog) get(path string) []map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.requests[path]
+}
+
+func newTestDatadogAPI(t *testing.T, url string, apiKey string) *DatadogAPI {
+	maxRetries := 2
+	d, err := NewDatadogAPI(config.DatadogAPIConfig{
+		Enabled:       true,
+		APIKey:        apiKey,
+		URL:           url,
+		Tags:          []string{"source:anemometer"},
+		BatchSize:     100,
+		FlushInterval: time.Hour,
+		MaxRetries:    &maxRetries,
+		RetryBackoff:  time.Millisecond,
+		Timeout:       time.Second,
+	})
+	assert.NoError(t, err)
+
+	return d
+}
+
+func TestDatadogAPISendMetrics(t *testing.T) {
+	fake := &fakeDatadog{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	d := newTestDatadogAPI(t, server.URL, "test-key")
+	timestamp := time.Unix(1700000000, 0)
+
+	assert.NoError(t, d.SendMetric(Metric{Name: "db.rows", Type: "gauge", Value: 42, Tags: []string{"table:users"}, Timestamp: timestamp}))
+	assert.NoError(t, d.SendMetric(Metric{Name: "db.inserts", Type: "count", Value: 7, Timestamp: timestamp}))
+	assert.NoError(t, d.SendMetric(Metric{Name: "db.latency", Type: "distribution", Value: 1.5, Timestamp: timestamp}))
+	assert.NoError(t, d.SendMetric(Metric{Name: "db.users", Type: "set", SetValue: "alice"}))
+	assert.Error(t, d.SendMetric(Metric{Name: "db.rows", Type: "summary"}))
+	assert.NoError(t, d.Close())
+
+	series := fake.get("/api/v2/series")
+	assert.Equal(t, 1, len(series))
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"metric": "db.rows",
+			"type":   float64(seriesTypeGauge),
+			"points": []interface{}{map[string]interface{}{"timestamp": float64(1700000000), "value": float64(42)}},
+			"tags":   []interface{}{"table:users", "source:anemometer"},
+		},
+		map[string]interface{}{
+			"metric": "db.inserts",
+			"type":   float64(seriesTypeCount),
+			"points": []interface{}{map[string]interface{}{"timestamp": float64(1700000000), "value": float64(7)}},
+			"tags":   []interface{}{"source:anemometer"},
+		},
+	}, series[0]["series"])
+
+	distributions := fake.get("/api/v1/distribution_points")
+	assert.Equal(t, 1, len(distributions))
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"metric": "db.latency",
+			"points": []interface{}{[]interface{}{float64(1700000000), []interface{}{1.5}}},
+			"tags":   []interface{}{"source:anemometer"},
+		},
+	}, distributions[0]["series"])
+}
+
+func TestDatadogAPIAggregatesHistograms(t *testing.T) {
+	fake := &fakeDatadog{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	d, err := NewDatadogAPI(config.DatadogAPIConfig{
+		Enabled:       true,
+		APIKey:        "test-key",
+		URL:           server.URL,
+		BatchSize:     100,
+		FlushInterval: 10 * time.Second,
+		Timeout:       time.Second,
+	})
+	assert.NoError(t, err)
+	timestamp := time.Unix(1700000000, 0)
+
+	for _, value := range []float64{4, 1, 3, 2} {
+		assert.NoError(t, d.SendMetric(Metric{Name: "db.query_time", Type: "histogram", Value: value, Timestamp: timestamp}))
+	}
+	assert.NoError(t, d.SendMetric(Metric{Name: "db.lock_wait", Type: "timing", Value: 250, Tags: []string{"table:users"}, Timestamp: timestamp}))
+	assert.NoError(t, d.Close())
+
+	// Nothing is sent as a distribution, which would aggregate differently
+	assert.Empty(t, fake.get("/api/v1/distribution_points"))
+
+	series := fake.get("/api/v2/series")
+	assert.Equal(t, 1, len(series))
+
+	type point struct {
+		metric   string
+		typ      float64
+		value    float64
+		interval interface{}
+	}
+	var points []point
+	for _, s := range series[0]["series"].([]interface{}) {
+		s := s.(map[string]interface{})
+		points = append(points, point{
+			metric:   s["metric"].(string),
+			typ:      s["type"].(float64),
+			value:    s["points"].([]interface{})[0].(map[string]interface{})["value"].(float64),
+			interval: s["interval"],
+		})
+	}
+
+	assert.Equal(t, []point{
+		{metric: "db.lock_wait.max", typ: seriesTypeGauge, value: 250},
+		{metric: "db.lock_wait.median", typ: seriesTypeGauge, value: 250},
+		{metric: "db.lock_wait.avg", typ: seriesTypeGauge, value: 250},
+		{metric: "db.lock_wait.count", typ: seriesTypeRate, value: 0.1, interval: float64(10)},
+		{metric: "db.lock_wait.95percentile", typ: seriesTypeGauge, value: 250},
+		{metric: "db.query_time.max", typ: seriesTypeGauge, value: 4},
+		{metric: "db.query_time.median", typ: seriesTypeGauge, value: 2},
+		{metric: "db.query_time.avg", typ: seriesTypeGauge, value: 2.5},
+		{metric: "db.query_time.count", typ: seriesTypeRate, value: 0.4, interval: float64(10)},
+		{metric: "db.query_time.95percentile", typ: seriesTypeGauge, value: 4},
+	}, points)
+}
+
+func TestDatadogAPIBatchSize(t *testing.T) {
+	fake := &fakeDatadog{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	d := newTestDatadogAPI(t, server.URL, "test-key")
+	d.config.BatchSize = 2
+	defer d.Close()
+
+	assert.NoError(t, d.SendMetric(Metric{Name: "db.rows", Type: "gauge", Value: 1}))
+	assert.NoError(t, d.SendMetric(Metric{Name: "db.rows", Type: "gauge", Value: 2}))
+
+	// A full batch is sent without waiting for the flush interval
+	assert.Eventually(t, func() bool {
+		return len(fake.get("/api/v2/series")) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestDatadogAPIRetries(t *testing.T) {
+	tests := []struct {
+		name        string
+		failures    int
+		expectedErr bool
+	}{
+		{name: "recovers", failures: 2},
+		{name: "gives_up", failures: 3, expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeDatadog{failures: tt.failures}
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			d := newTestDatadogAPI(t, server.URL, "test-key")
+			defer d.Close()
+
+			err := d.SendEvent(Event{Title: "Long running query", Text: "pid 41273", AlertType: "warning", Priority: "normal"})
+			if tt.expectedErr {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), "503")
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, []map[string]interface{}{{
+				"title":      "Long running query",
+				"text":       "pid 41273",
+				"alert_type": "warning",
+				"priority":   "normal",
+				"tags":       []interface{}{"source:anemometer"},
+			}}, fake.get("/api/v1/events"))
+		})
+	}
+}
+
+func TestDatadogAPIDoesNotRetryClientErrors(t *testing.T) {
+	fake := &fakeDatadog{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	d := newTestDatadogAPI(t, server.URL, "wrong-key")
+	defer d.Close()
+
+	err := d.SendEvent(Event{Title: "Long running query"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "403")
+}
@@ -0,0 +1,312 @@
+package sink
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/simplifi/anemometer/pkg/anemometer/config"
+)
+
+const (
+	// maxGraphiteBackoff caps how long reconnecting waits after Graphite has
+	// been unreachable
+	maxGraphiteBackoff = time.Minute
+	// maxGraphiteBuffer caps the bytes of lines kept while reconnecting backs
+	// off, metrics past it are refused
+	maxGraphiteBuffer = 1 << 20
+	// graphiteRetryInterval is how often buffered lines are retried when no
+	// new metric wakes the flush
+	graphiteRetryInterval = time.Second
+)
+
+// Graphite sends metrics over the Graphite plaintext protocol, building each
+// metric's dotted path from the configured template. Lines are buffered and
+// written in the background, so that an unreachable Graphite doesn't hold up
+// the monitors. Graphite has no set type or events, so they are dropped.
+type Graphite struct {
+	config   config.GraphiteConfig
+	template *template.Template
+
+	mu    sync.Mutex
+	lines bytes.Buffer
+
+	// connMu guards the connection and reconnect backoff, which are only used
+	// when flushing
+	connMu  sync.Mutex
+	conn    net.Conn
+	backoff time.Duration
+	retryAt time.Time
+
+	setWarning   sync.Once
+	eventWarning sync.Once
+	flush        chan struct{}
+	done         chan struct{}
+	wg           sync.WaitGroup
+}
+
+// GraphitePath is the data the path template is executed with
+type GraphitePath struct {
+	Name    string
+	Monitor string
+	Type    string
+	// Tags are in the order they were sent, split into keys and values
+	Tags []GraphiteTag
+}
+
+// GraphiteTag is a single tag, with its value made safe for a path
+type GraphiteTag struct {
+	Key   string
+	Value string
+}
+
+// Tag returns the value of the tag with the given key, or "none" if the metric
+// doesn't have it, so that paths keep the same number of nodes
+func (p GraphitePath) Tag(key string) string {
+	for _, tag := range p.Tags {
+		if tag.Key == key {
+			return tag.Value
+		}
+	}
+
+	return "none"
+}
+
+// NewGraphite creates a Graphite sink from an already normalized config. The
+// connection is made when the first metric is flushed.
+func NewGraphite(graphiteConfig config.GraphiteConfig) (*Graphite, error) {
+	pathTemplate, err := template.New("graphite").Parse(graphiteConfig.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &Graphite{
+		config:   graphiteConfig,
+		template: pathTemplate,
+		flush:    make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+
+	g.wg.Add(1)
+	go g.run()
+
+	return g, nil
+}
+
+// SendMetric buffers the metric and wakes the background flush
+func (g *Graphite) SendMetric(metric Metric) error {
+	if !sampled(metric) {
+		return nil
+	}
+
+	value := scaledValue(metric)
+	switch metric.Type {
+	case "gauge", "count", "histogram", "distribution", "timing":
+	case "set":
+		g.setWarning.Do(func() {
+			log.Printf("WARN: set metrics cannot be sent to Graphite, dropping %s", metric.Name)
+		})
+		return nil
+	default:
+		return fmt.Errorf("unknown metric type: %s", metric.Type)
+	}
+
+	if math.IsInf(value, 0) || math.IsNaN(value) {
+		return fmt.Errorf("value cannot be sent to Graphite: %v", value)
+	}
+
+	path, err := g.path(metric)
+	if err != nil {
+		return err
+	}
+
+	timestamp := metric.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	line := fmt.Sprintf("%s %s %d\n", path, strconv.FormatFloat(value, 'f', -1, 64), timestamp.Unix())
+
+	g.mu.Lock()
+	if g.lines.Len()+len(line) > maxGraphiteBuffer {
+		g.mu.Unlock()
+		return fmt.Errorf("graphite buffer is full, dropping %s", metric.Name)
+	}
+	g.lines.WriteString(line)
+	g.mu.Unlock()
+
+	select {
+	case g.flush <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// SendEvent drops the event, the plaintext protocol has no events
+func (g *Graphite) SendEvent(event Event) error {
+	g.eventWarning.Do(func() {
+		log.Printf("WARN: events cannot be sent to Graphite, dropping events from %s", event.Monitor)
+	})
+
+	return nil
+}
+
+// Flush writes every buffered line, reconnecting once if the connection has
+// been lost. When Graphite can't be reached, the lines being written are
+// dropped and reconnecting backs off, keeping the lines sent meanwhile
+// buffered until the next attempt.
+func (g *Graphite) Flush() error {
+	g.connMu.Lock()
+	defer g.connMu.Unlock()
+
+	if g.conn == nil && time.Now().Before(g.retryAt) {
+		return nil
+	}
+
+	g.mu.Lock()
+	body := bytes.Clone(g.lines.Bytes())
+	g.lines.Reset()
+	g.mu.Unlock()
+
+	if len(body) == 0 {
+		return nil
+	}
+
+	err := g.write(body)
+	if err != nil {
+		g.closeConn()
+		err = g.write(body)
+	}
+	if err != nil {
+		g.closeConn()
+		g.backoff = min(max(2*g.backoff, time.Second), maxGraphiteBackoff)
+		g.retryAt = time.Now().Add(g.backoff)
+		return fmt.Errorf("%w, dropped %d lines, reconnecting in %s", err, bytes.Count(body, []byte("\n")), g.backoff)
+	}
+
+	g.backoff = 0
+
+	return nil
+}
+
+// Close stops the background flushing, sends whatever is still buffered and
+// closes the connection
+func (g *Graphite) Close() error {
+	close(g.done)
+	g.wg.Wait()
+
+	err := g.Flush()
+
+	g.mu.Lock()
+	if g.lines.Len() > 0 {
+		err = errors.Join(err, fmt.Errorf("graphite is unreachable, dropped %d buffered lines",
+			bytes.Count(g.lines.Bytes(), []byte("\n"))))
+	}
+	g.mu.Unlock()
+
+	g.connMu.Lock()
+	defer g.connMu.Unlock()
+
+	return errors.Join(err, g.closeConn())
+}
+
+func (g *Graphite) run() {
+	defer g.wg.Done()
+
+	ticker := time.NewTicker(graphiteRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-g.done:
+			return
+		case <-ticker.C:
+		case <-g.flush:
+		}
+
+		if err := g.Flush(); err != nil {
+			log.Printf("ERROR: Failed to write metrics to Graphite: %v", err)
+		}
+	}
+}
+
+// path executes the template for the metric
+func (g *Graphite) path(metric Metric) (string, error) {
+	data := GraphitePath{
+		Name:    graphiteSanitize(metric.Name, true),
+		Monitor: graphiteSanitize(metric.Monitor, false),
+		Type:    metric.Type,
+	}
+	for _, tag := range metric.Tags {
+		key, value, _ := strings.Cut(tag, ":")
+		data.Tags = append(data.Tags, GraphiteTag{Key: key, Value: graphiteSanitize(value, false)})
+	}
+
+	var path bytes.Buffer
+	if err := g.template.Execute(&path, data); err != nil {
+		return "", err
+	}
+
+	return path.String(), nil
+}
+
+// write sends the lines, connecting first if needed. The caller must hold
+// connMu.
+func (g *Graphite) write(lines []byte) error {
+	if g.conn == nil {
+		conn, err := net.DialTimeout("tcp", g.config.Address, g.config.Timeout)
+		if err != nil {
+			return err
+		}
+		g.conn = conn
+	}
+
+	if err := g.conn.SetWriteDeadline(time.Now().Add(g.config.Timeout)); err != nil {
+		return err
+	}
+
+	_, err := g.conn.Write(lines)
+	return err
+}
+
+// closeConn closes the connection, if there is one. The caller must hold
+// connMu.
+func (g *Graphite) closeConn() error {
+	if g.conn == nil {
+		return nil
+	}
+
+	err := g.conn.Close()
+	g.conn = nil
+
+	return err
+}
+
+// graphiteSanitize replaces everything but letters, digits, '-' and '_' with
+// '_', as other characters would break up or corrupt the path. Dots are kept
+// when allowed, so metric names keep their hierarchy.
+func graphiteSanitize(value string, allowDots bool) string {
+	if value == "" {
+		return "none"
+	}
+
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		case r == '.' && allowDots:
+			return r
+		default:
+			return '_'
+		}
+	}, value)
+}
@@ -0,0 +1,130 @@
+package sink
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/simplifi/anemometer/pkg/anemometer/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraphitePath(t *testing.T) {
+	metric := Metric{
+		Monitor: "table rows",
+		Name:    "db.rows",
+		Type:    "gauge",
+		Tags:    []string{"environment:prod", "table:public.users", "valueless"},
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{
+			name:     "default",
+			template: config.DefaultGraphiteTemplate,
+			expected: "db.rows.prod.public_users.none",
+		},
+		{
+			name:     "tag_lookup",
+			template: "anemometer.{{.Tag \"environment\"}}.{{.Monitor}}.{{.Name}}.{{.Tag \"table\"}}.{{.Tag \"missing\"}}",
+			expected: "anemometer.prod.table_rows.db.rows.public_users.none",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graphite, err := NewGraphite(config.GraphiteConfig{Template: tt.template})
+			assert.NoError(t, err)
+
+			path, err := graphite.path(metric)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, path)
+		})
+	}
+}
+
+func TestGraphiteSendMetric(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	lines := make(chan string, 10)
+	go func() {
+		// Accept twice, the sink reconnects after the first connection drops
+		for i := 0; i < 2; i++ {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			lines <- line
+			conn.Close()
+		}
+	}()
+
+	graphite, err := NewGraphite(config.GraphiteConfig{
+		Enabled:  true,
+		Address:  listener.Addr().String(),
+		Template: config.DefaultGraphiteTemplate,
+		Timeout:  5 * time.Second,
+	})
+	assert.NoError(t, err)
+	defer graphite.Close()
+
+	timestamp := time.Unix(1700000000, 0)
+	assert.NoError(t, graphite.SendMetric(Metric{Name: "db.rows", Type: "gauge", Value: 42, Tags: []string{"table:users"}, Timestamp: timestamp}))
+	assert.Equal(t, "db.rows.users 42 1700000000\n", <-lines)
+
+	// Writes to the closed connection fail until the close is noticed, after
+	// which the sink reconnects
+	assert.Eventually(t, func() bool {
+		return graphite.SendMetric(Metric{Name: "db.inserts", Type: "count", Value: 3, Timestamp: timestamp}) == nil &&
+			len(lines) > 0
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.Equal(t, "db.inserts 3 1700000000\n", <-lines)
+
+	assert.NoError(t, graphite.SendMetric(Metric{Name: "db.users", Type: "set", SetValue: "alice"}))
+	assert.Error(t, graphite.SendMetric(Metric{Name: "db.rows", Type: "summary"}))
+}
+
+func TestGraphiteUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	address := listener.Addr().String()
+	listener.Close()
+
+	graphite, err := NewGraphite(config.GraphiteConfig{
+		Enabled:  true,
+		Address:  address,
+		Template: config.DefaultGraphiteTemplate,
+		Timeout:  5 * time.Second,
+	})
+	assert.NoError(t, err)
+	// Stop the background flushing so the test flushes on its own
+	close(graphite.done)
+	graphite.wg.Wait()
+
+	// Sending only buffers, it never waits on the connection
+	assert.NoError(t, graphite.SendMetric(Metric{Name: "db.rows", Type: "gauge", Value: 1}))
+	assert.Error(t, graphite.Flush())
+
+	assert.Equal(t, 0, graphite.lines.Len())
+
+	// Reconnecting backs off, so the next flush keeps the lines buffered
+	// without dialing
+	assert.NoError(t, graphite.SendMetric(Metric{Name: "db.rows", Type: "gauge", Value: 2}))
+	assert.NoError(t, graphite.Flush())
+	assert.True(t, strings.HasPrefix(graphite.lines.String(), "db.rows 2 "))
+	assert.Equal(t, time.Second, graphite.backoff)
+
+	// Metrics past the buffer limit are refused
+	graphite.lines.Write(make([]byte, maxGraphiteBuffer))
+	assert.Error(t, graphite.SendMetric(Metric{Name: "db.rows", Type: "gauge", Value: 3}))
+
+	assert.NoError(t, graphite.closeConn())
+}
@@ -0,0 +1,94 @@
+package sink
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// httpPoster posts request bodies, retrying failures that may be temporary
+// (network errors, 408, 429 and 5xx responses) with exponential backoff
+type httpPoster struct {
+	client     *http.Client
+	headers    map[string]string
+	maxRetries int
+	backoff    time.Duration
+}
+
+// retryableError marks failures that are worth retrying
+type retryableError struct {
+	err error
+}
+
+func (e retryableError) Error() string {
+	return e.err.Error()
+}
+
+// retryCount returns the configured retries, or none if they weren't set
+func retryCount(maxRetries *int) int {
+	if maxRetries == nil {
+		return 0
+	}
+
+	return *maxRetries
+}
+
+func newHTTPPoster(timeout time.Duration, headers map[string]string, maxRetries int, backoff time.Duration) *httpPoster {
+	return &httpPoster{
+		client:     &http.Client{Timeout: timeout},
+		headers:    headers,
+		maxRetries: maxRetries,
+		backoff:    backoff,
+	}
+}
+
+// post sends the body to the URL, retrying up to maxRetries times
+func (p *httpPoster) post(url string, body []byte) error {
+	backoff := p.backoff
+	for attempt := 0; ; attempt++ {
+		err := p.send(url, body)
+
+		var retryable retryableError
+		if err == nil || !errors.As(err, &retryable) || attempt >= p.maxRetries {
+			return err
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (p *httpPoster) send(url string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, value := range p.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return retryableError{err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("POST %s: %s: %s", req.URL.Path, resp.Status, bytes.TrimSpace(message))
+
+	switch {
+	case resp.StatusCode >= 500, resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode == http.StatusRequestTimeout:
+		return retryableError{err}
+	default:
+		return err
+	}
+}
@@ -0,0 +1,291 @@
+package sink
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/simplifi/anemometer/pkg/anemometer/config"
+)
+
+// InfluxDB writes metrics as InfluxDB line protocol, either buffered and
+// posted to the HTTP write API or sent immediately over UDP. The metric name
+// is the measurement and its value is written to the "value" field, as a
+// float or, for sets, a string. Events are dropped.
+type InfluxDB struct {
+	config   config.InfluxDBConfig
+	client   *http.Client
+	writeURL string
+	conn     net.Conn
+
+	mu    sync.Mutex
+	lines bytes.Buffer
+	count int
+
+	eventWarning sync.Once
+	flush        chan struct{}
+	done         chan struct{}
+	wg           sync.WaitGroup
+}
+
+// influxTag is a single key=value pair of a line
+type influxTag struct {
+	key   string
+	value string
+}
+
+// NewInfluxDB creates an InfluxDB sink from an already normalized config
+func NewInfluxDB(influxConfig config.InfluxDBConfig) (*InfluxDB, error) {
+	i := &InfluxDB{config: influxConfig}
+
+	if address, found := strings.CutPrefix(influxConfig.URL, "udp://"); found {
+		conn, err := net.Dial("udp", address)
+		if err != nil {
+			return nil, err
+		}
+		i.conn = conn
+
+		return i, nil
+	}
+
+	writeURL, err := influxWriteURL(influxConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	i.writeURL = writeURL
+	i.client = &http.Client{Timeout: influxConfig.Timeout}
+	i.flush = make(chan struct{}, 1)
+	i.done = make(chan struct{})
+
+	i.wg.Add(1)
+	go i.run()
+
+	return i, nil
+}
+
+// SendMetric writes the metric over UDP, or buffers it until the next flush
+func (i *InfluxDB) SendMetric(metric Metric) error {
+	if !sampled(metric) {
+		return nil
+	}
+
+	line, err := i.line(metric)
+	if err != nil {
+		return err
+	}
+
+	if i.conn != nil {
+		_, err := i.conn.Write(line)
+		return err
+	}
+
+	i.mu.Lock()
+	i.lines.Write(line)
+	i.count++
+	full := i.count >= i.config.BatchSize
+	i.mu.Unlock()
+
+	if full {
+		select {
+		case i.flush <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// SendEvent drops the event, InfluxDB has no events
+func (i *InfluxDB) SendEvent(event Event) error {
+	i.eventWarning.Do(func() {
+		log.Printf("WARN: events cannot be written to InfluxDB, dropping events from %s", event.Monitor)
+	})
+
+	return nil
+}
+
+// Flush posts every buffered line to the write API
+func (i *InfluxDB) Flush() error {
+	if i.conn != nil {
+		return nil
+	}
+
+	i.mu.Lock()
+	body := bytes.Clone(i.lines.Bytes())
+	i.lines.Reset()
+	i.count = 0
+	i.mu.Unlock()
+
+	if len(body) == 0 {
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodPost, i.writeURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if i.config.Token != "" {
+		req.Header.Set("Authorization", "Token "+i.config.Token)
+	}
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("POST %s: %s: %s", req.URL.Path, resp.Status, bytes.TrimSpace(message))
+}
+
+// Close stops the background flushing and sends whatever is still buffered
+func (i *InfluxDB) Close() error {
+	if i.conn != nil {
+		return i.conn.Close()
+	}
+
+	close(i.done)
+	i.wg.Wait()
+
+	return i.Flush()
+}
+
+func (i *InfluxDB) run() {
+	defer i.wg.Done()
+
+	ticker := time.NewTicker(i.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-i.done:
+			return
+		case <-ticker.C:
+		case <-i.flush:
+		}
+
+		if err := i.Flush(); err != nil {
+			log.Printf("ERROR: Failed to write metrics to InfluxDB: %v", err)
+		}
+	}
+}
+
+// line formats the metric as a single line of line protocol
+func (i *InfluxDB) line(metric Metric) ([]byte, error) {
+	var field string
+
+	switch metric.Type {
+	case "set":
+		field = `"` + influxEscape(metric.SetValue, `"\`) + `"`
+	case "gauge", "count", "histogram", "distribution", "timing":
+		value := scaledValue(metric)
+		if math.IsInf(value, 0) || math.IsNaN(value) {
+			return nil, fmt.Errorf("value cannot be written to InfluxDB: %v", value)
+		}
+		field = strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return nil, fmt.Errorf("unknown metric type: %s", metric.Type)
+	}
+
+	timestamp := metric.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	var line bytes.Buffer
+	line.WriteString(influxEscape(metric.Name, ", "))
+	for _, tag := range influxTags(append(append([]string{}, metric.Tags...), i.config.Tags...)) {
+		line.WriteString(",")
+		line.WriteString(influxEscape(tag.key, ",= "))
+		line.WriteString("=")
+		line.WriteString(influxEscape(tag.value, ",= "))
+	}
+	fmt.Fprintf(&line, " value=%s %d\n", field, timestamp.UnixNano())
+
+	return line.Bytes(), nil
+}
+
+// influxTags splits key:value tags, sorted by key as InfluxDB recommends.
+// InfluxDB has no empty tag values, so tags without a value are skipped, and
+// only the first value of a repeated key is kept.
+func influxTags(tags []string) []influxTag {
+	seen := make(map[string]struct{}, len(tags))
+	influx := make([]influxTag, 0, len(tags))
+
+	for _, tag := range tags {
+		key, value, _ := strings.Cut(tag, ":")
+		if key == "" || value == "" {
+			continue
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		influx = append(influx, influxTag{key: key, value: value})
+	}
+
+	sort.SliceStable(influx, func(a, b int) bool {
+		return influx[a].key < influx[b].key
+	})
+
+	return influx
+}
+
+// influxEscape backslash escapes the given special characters
+func influxEscape(value string, special string) string {
+	if !strings.ContainsAny(value, special) {
+		return value
+	}
+
+	var escaped strings.Builder
+	for _, r := range value {
+		if strings.ContainsRune(special, r) {
+			escaped.WriteRune('\\')
+		}
+		escaped.WriteRune(r)
+	}
+
+	return escaped.String()
+}
+
+// influxWriteURL builds the 2.x write URL when a bucket is configured and the
+// 1.x one otherwise
+func influxWriteURL(influxConfig config.InfluxDBConfig) (string, error) {
+	query := url.Values{}
+	query.Set("precision", "ns")
+
+	var path string
+	switch {
+	case influxConfig.Bucket != "":
+		path = "/api/v2/write"
+		query.Set("bucket", influxConfig.Bucket)
+		if influxConfig.Org != "" {
+			query.Set("org", influxConfig.Org)
+		}
+	case influxConfig.Database != "":
+		path = "/write"
+		query.Set("db", influxConfig.Database)
+	default:
+		return "", errors.New("influxdb database or bucket is not set")
+	}
+
+	return influxConfig.URL + path + "?" + query.Encode(), nil
+}
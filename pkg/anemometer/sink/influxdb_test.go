@@ -0,0 +1,145 @@
+package sink
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/simplifi/anemometer/pkg/anemometer/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInfluxDBLine(t *testing.T) {
+	timestamp := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name        string
+		metric      Metric
+		expected    string
+		expectedErr string
+	}{
+		{
+			name:     "gauge",
+			metric:   Metric{Name: "db.rows", Type: "gauge", Value: 42.5, Tags: []string{"table:users", "environment:prod"}, Timestamp: timestamp},
+			expected: "db.rows,environment=prod,source=anemometer,table=users value=42.5 1700000000000000000\n",
+		},
+		{
+			name:     "sampled_count",
+			metric:   Metric{Name: "db.inserts", Type: "count", Value: 5, SampleRate: 0.5, Timestamp: timestamp},
+			expected: "db.inserts,source=anemometer value=10 1700000000000000000\n",
+		},
+		{
+			name:     "set",
+			metric:   Metric{Name: "db.users", Type: "set", SetValue: `say "hi"`, Timestamp: timestamp},
+			expected: `db.users,source=anemometer value="say \"hi\"" 1700000000000000000` + "\n",
+		},
+		{
+			name:     "escaping",
+			metric:   Metric{Name: "db rows,total", Type: "gauge", Value: 1, Tags: []string{"query:a=b, c", "valueless", "source:override"}, Timestamp: timestamp},
+			expected: `db\ rows\,total,query=a\=b\,\ c,source=override value=1 1700000000000000000` + "\n",
+		},
+		{
+			name:        "unknown_type",
+			metric:      Metric{Name: "db.rows", Type: "summary"},
+			expectedErr: "unknown metric type: summary",
+		},
+	}
+
+	influx := &InfluxDB{config: config.InfluxDBConfig{Tags: []string{"source:anemometer"}}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, err := influx.line(tt.metric)
+			if tt.expectedErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, string(line))
+		})
+	}
+}
+
+func TestInfluxDBHTTP(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        config.InfluxDBConfig
+		expectedPath  string
+		expectedQuery string
+		expectedAuth  string
+	}{
+		{
+			name:          "v1",
+			config:        config.InfluxDBConfig{Database: "metrics"},
+			expectedPath:  "/write",
+			expectedQuery: "db=metrics&precision=ns",
+		},
+		{
+			name:          "v2",
+			config:        config.InfluxDBConfig{Org: "data", Bucket: "metrics", Token: "secret"},
+			expectedPath:  "/api/v2/write",
+			expectedQuery: "bucket=metrics&org=data&precision=ns",
+			expectedAuth:  "Token secret",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := make(chan *http.Request, 1)
+			bodies := make(chan string, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				requests <- r
+				bodies <- string(body)
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			tt.config.Enabled = true
+			tt.config.URL = server.URL
+			tt.config.BatchSize = 100
+			tt.config.FlushInterval = time.Hour
+			tt.config.Timeout = 5 * time.Second
+
+			influx, err := NewInfluxDB(tt.config)
+			assert.NoError(t, err)
+
+			timestamp := time.Unix(1700000000, 0)
+			assert.NoError(t, influx.SendMetric(Metric{Name: "db.rows", Type: "gauge", Value: 1, Timestamp: timestamp}))
+			assert.NoError(t, influx.SendMetric(Metric{Name: "db.rows", Type: "gauge", Value: 2, Timestamp: timestamp}))
+			assert.NoError(t, influx.Close())
+
+			r := <-requests
+			assert.Equal(t, tt.expectedPath, r.URL.Path)
+			assert.Equal(t, tt.expectedQuery, r.URL.RawQuery)
+			assert.Equal(t, tt.expectedAuth, r.Header.Get("Authorization"))
+			assert.Equal(t, "db.rows value=1 1700000000000000000\ndb.rows value=2 1700000000000000000\n", <-bodies)
+		})
+	}
+}
+
+func TestInfluxDBUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	influx, err := NewInfluxDB(config.InfluxDBConfig{
+		Enabled: true,
+		URL:     "udp://" + conn.LocalAddr().String(),
+	})
+	assert.NoError(t, err)
+	defer influx.Close()
+
+	assert.NoError(t, influx.SendMetric(Metric{Name: "db.rows", Type: "gauge", Value: 42, Timestamp: time.Unix(1700000000, 0)}))
+
+	buffer := make([]byte, 1024)
+	assert.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buffer)
+	assert.NoError(t, err)
+	assert.Equal(t, "db.rows value=42 1700000000000000000\n", string(buffer[:n]))
+}
@@ -0,0 +1,237 @@
+package sink
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/simplifi/anemometer/pkg/anemometer/config"
+)
+
+// JSONLines writes every metric and event as a line of JSON, to stdout or to
+// a file that is rotated once it grows past MaxSizeMB
+type JSONLines struct {
+	mu     sync.Mutex
+	writer io.Writer
+	file   *rotatingFile
+}
+
+// jsonLinesRecord is a single line. Metric and event fields are omitted from
+// the other kind of record.
+type jsonLinesRecord struct {
+	Kind      string    `json:"kind"`
+	Monitor   string    `json:"monitor"`
+	Timestamp time.Time `json:"timestamp"`
+	Tags      []string  `json:"tags"`
+
+	Name       string      `json:"name,omitempty"`
+	Type       string      `json:"type,omitempty"`
+	Value      interface{} `json:"value,omitempty"`
+	SampleRate float64     `json:"sample_rate,omitempty"`
+
+	Title          string `json:"title,omitempty"`
+	Text           string `json:"text,omitempty"`
+	AlertType      string `json:"alert_type,omitempty"`
+	Priority       string `json:"priority,omitempty"`
+	SourceTypeName string `json:"source_type_name,omitempty"`
+	AggregationKey string `json:"aggregation_key,omitempty"`
+	Hostname       string `json:"hostname,omitempty"`
+}
+
+// NewJSONLines creates a JSONLines sink from an already normalized config
+func NewJSONLines(jsonLinesConfig config.JSONLinesConfig) (*JSONLines, error) {
+	if jsonLinesConfig.Path == "-" {
+		return NewJSONLinesWithWriter(os.Stdout), nil
+	}
+
+	var maxBackups int
+	if jsonLinesConfig.MaxBackups != nil {
+		maxBackups = *jsonLinesConfig.MaxBackups
+	}
+
+	file, err := openRotatingFile(jsonLinesConfig.Path, int64(jsonLinesConfig.MaxSizeMB)*1024*1024, maxBackups)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JSONLines{writer: file, file: file}, nil
+}
+
+// NewJSONLinesWithWriter creates a JSONLines sink writing to an existing
+// writer
+func NewJSONLinesWithWriter(writer io.Writer) *JSONLines {
+	return &JSONLines{writer: writer}
+}
+
+// SendMetric writes the metric. Sampling is not applied, so that every metric
+// is recorded, but the sample rate is.
+func (j *JSONLines) SendMetric(metric Metric) error {
+	record := jsonLinesRecord{
+		Kind:       "metric",
+		Monitor:    metric.Monitor,
+		Timestamp:  metric.Timestamp,
+		Tags:       metric.Tags,
+		Name:       metric.Name,
+		Type:       metric.Type,
+		Value:      metric.Value,
+		SampleRate: sampleRate(metric),
+	}
+	if metric.Type == "set" {
+		record.Value = metric.SetValue
+	}
+
+	return j.write(record)
+}
+
+// SendEvent writes the event
+func (j *JSONLines) SendEvent(event Event) error {
+	return j.write(jsonLinesRecord{
+		Kind:           "event",
+		Monitor:        event.Monitor,
+		Timestamp:      event.Timestamp,
+		Tags:           event.Tags,
+		Title:          event.Title,
+		Text:           event.Text,
+		AlertType:      event.AlertType,
+		Priority:       event.Priority,
+		SourceTypeName: event.SourceTypeName,
+		AggregationKey: event.AggregationKey,
+		Hostname:       event.Hostname,
+	})
+}
+
+// Close closes the file, stdout is left open
+func (j *JSONLines) Close() error {
+	if j.file == nil {
+		return nil
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.file.Close()
+}
+
+func (j *JSONLines) write(record jsonLinesRecord) error {
+	if record.Timestamp.IsZero() {
+		record.Timestamp = time.Now()
+	}
+	if record.Tags == nil {
+		record.Tags = []string{}
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	// Lines are written in one call so they never interleave
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	_, err = j.writer.Write(line)
+	return err
+}
+
+// rotatingFile is a file that is renamed to path.1 once it would grow past
+// maxSize, shifting older backups along and removing those past maxBackups
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	file *os.File
+	size int64
+}
+
+func openRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Write appends p, rotating first if p would take the file past maxSize
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+
+	return n, err
+}
+
+func (r *rotatingFile) Close() error {
+	return r.file.Close()
+}
+
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	r.file = file
+	r.size = info.Size()
+
+	return nil
+}
+
+// rotate moves the file to the first backup and opens a new one. If that
+// fails, the current file is reopened so later writes can still succeed.
+func (r *rotatingFile) rotate() error {
+	err := r.file.Close()
+	if err == nil {
+		err = r.shiftBackups()
+	}
+	if err != nil {
+		if openErr := r.open(); openErr != nil {
+			return errors.Join(err, openErr)
+		}
+		return err
+	}
+
+	return r.open()
+}
+
+// shiftBackups renames the file and its backups along by one, removing the
+// oldest
+func (r *rotatingFile) shiftBackups() error {
+	os.Remove(r.backupPath(r.maxBackups))
+	for i := r.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(r.backupPath(i), r.backupPath(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if r.maxBackups > 0 {
+		if err := os.Rename(r.path, r.backupPath(1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(r.path); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *rotatingFile) backupPath(i int) string {
+	return fmt.Sprintf("%s.%d", r.path, i)
+}
@@ -0,0 +1,101 @@
+package sink
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/simplifi/anemometer/pkg/anemometer/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONLines(t *testing.T) {
+	var buffer bytes.Buffer
+	j := NewJSONLinesWithWriter(&buffer)
+	timestamp := time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)
+
+	assert.NoError(t, j.SendMetric(Metric{
+		Monitor:    "rows",
+		Name:       "db.rows",
+		Type:       "gauge",
+		Value:      0,
+		Tags:       []string{"table:users"},
+		Timestamp:  timestamp,
+		SampleRate: 0.5,
+	}))
+	assert.NoError(t, j.SendMetric(Metric{Monitor: "users", Name: "db.users", Type: "set", SetValue: "alice", Timestamp: timestamp}))
+	assert.NoError(t, j.SendEvent(Event{
+		Monitor:   "queries",
+		Title:     "Long running query",
+		Text:      "pid 41273",
+		AlertType: "warning",
+		Priority:  "normal",
+		Tags:      []string{"database:analytics"},
+		Timestamp: timestamp,
+	}))
+	assert.NoError(t, j.Close())
+
+	assert.Equal(t, []string{
+		`{"kind":"metric","monitor":"rows","timestamp":"2023-11-14T22:13:20Z","tags":["table:users"],"name":"db.rows","type":"gauge","value":0,"sample_rate":0.5}`,
+		`{"kind":"metric","monitor":"users","timestamp":"2023-11-14T22:13:20Z","tags":[],"name":"db.users","type":"set","value":"alice","sample_rate":1}`,
+		`{"kind":"event","monitor":"queries","timestamp":"2023-11-14T22:13:20Z","tags":["database:analytics"],"title":"Long running query","text":"pid 41273","alert_type":"warning","priority":"normal"}`,
+	}, strings.Split(strings.TrimSpace(buffer.String()), "\n"))
+}
+
+func TestJSONLinesRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "anemometer.jsonl")
+	maxBackups := 2
+
+	j, err := NewJSONLines(config.JSONLinesConfig{Enabled: true, Path: path, MaxSizeMB: 1, MaxBackups: &maxBackups})
+	assert.NoError(t, err)
+
+	// Each line is a little over 100KB, so every ten lines fill a file
+	metric := Metric{Monitor: "rows", Name: "db.rows", Type: "gauge", Tags: []string{strings.Repeat("x", 100*1024)}}
+	for i := 0; i < 35; i++ {
+		assert.NoError(t, j.SendMetric(metric))
+	}
+	assert.NoError(t, j.Close())
+
+	for _, name := range []string{"anemometer.jsonl", "anemometer.jsonl.1", "anemometer.jsonl.2"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, info.Size(), int64(1024*1024))
+	}
+	_, err = os.Stat(filepath.Join(dir, "anemometer.jsonl.3"))
+	assert.True(t, os.IsNotExist(err))
+
+	// Reopening appends to the existing file
+	j, err = NewJSONLines(config.JSONLinesConfig{Enabled: true, Path: path, MaxSizeMB: 1, MaxBackups: &maxBackups})
+	assert.NoError(t, err)
+	assert.NotZero(t, j.file.size)
+	assert.NoError(t, j.Close())
+}
+
+func TestJSONLinesRotationFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "anemometer.jsonl")
+	maxBackups := 1
+
+	// A directory in the way of the backup makes the rename fail
+	assert.NoError(t, os.MkdirAll(filepath.Join(path+".1", "blocked"), 0755))
+
+	j, err := NewJSONLines(config.JSONLinesConfig{Enabled: true, Path: path, MaxSizeMB: 1, MaxBackups: &maxBackups})
+	assert.NoError(t, err)
+	defer j.Close()
+
+	metric := Metric{Monitor: "rows", Name: "db.rows", Type: "gauge", Tags: []string{strings.Repeat("x", 600*1024)}}
+	assert.NoError(t, j.SendMetric(metric))
+	assert.Error(t, j.SendMetric(metric))
+
+	// The file was reopened, so writing recovers once the rename can succeed
+	assert.NoError(t, os.RemoveAll(path+".1"))
+	assert.NoError(t, j.SendMetric(metric))
+
+	info, err := os.Stat(path + ".1")
+	assert.NoError(t, err)
+	assert.True(t, info.Mode().IsRegular())
+}
@@ -0,0 +1,237 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/simplifi/anemometer/pkg/anemometer/config"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// OTLP exports metrics through the OpenTelemetry SDK. Gauges become gauges,
+// counts become monotonic sums and histograms, distributions and timings
+// become histograms, each recorded under a scope named after the monitor.
+// Tags become attributes. OpenTelemetry has no events, so they are dropped.
+type OTLP struct {
+	provider *sdkmetric.MeterProvider
+
+	mu          sync.Mutex
+	instruments map[otlpInstrumentKey]interface{}
+
+	setWarning   sync.Once
+	eventWarning sync.Once
+}
+
+type otlpInstrumentKey struct {
+	scope string
+	name  string
+	kind  string
+}
+
+// NewOTLP creates an OTLP sink exporting over gRPC or HTTP from an already
+// normalized config
+func NewOTLP(otlpConfig config.OTLPConfig) (*OTLP, error) {
+	ctx := context.Background()
+
+	var exporter sdkmetric.Exporter
+	var err error
+
+	switch otlpConfig.Protocol {
+	case "grpc":
+		exporter, err = otlpmetricgrpc.New(ctx, otlpGRPCOptions(otlpConfig)...)
+	case "http":
+		exporter, err = otlpmetrichttp.New(ctx, otlpHTTPOptions(otlpConfig)...)
+	default:
+		return nil, fmt.Errorf("unknown otlp protocol: %s", otlpConfig.Protocol)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithAttributes(attribute.String("service.name", otlpConfig.ServiceName)),
+		resource.WithAttributes(tagsToAttributes(otlpConfig.Tags)...),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(otlpConfig.ExportInterval))
+
+	return NewOTLPWithReader(reader, res), nil
+}
+
+// NewOTLPWithReader creates an OTLP sink that records into an existing reader
+func NewOTLPWithReader(reader sdkmetric.Reader, res *resource.Resource) *OTLP {
+	options := []sdkmetric.Option{sdkmetric.WithReader(reader)}
+	if res != nil {
+		options = append(options, sdkmetric.WithResource(res))
+	}
+
+	return &OTLP{
+		provider:    sdkmetric.NewMeterProvider(options...),
+		instruments: make(map[otlpInstrumentKey]interface{}),
+	}
+}
+
+// SendMetric records the metric with the instrument matching its Type
+func (o *OTLP) SendMetric(metric Metric) error {
+	if !sampled(metric) {
+		return nil
+	}
+
+	ctx := context.Background()
+	attributes := metricAttributes(metric.Tags)
+
+	switch metric.Type {
+	case "gauge":
+		gauge, err := o.instrument(metric, "gauge")
+		if err != nil {
+			return err
+		}
+		gauge.(otelmetric.Float64Gauge).Record(ctx, metric.Value, attributes)
+	case "count":
+		if metric.Value < 0 {
+			return fmt.Errorf("count values cannot be negative: %v", metric.Value)
+		}
+		counter, err := o.instrument(metric, "count")
+		if err != nil {
+			return err
+		}
+		counter.(otelmetric.Float64Counter).Add(ctx, scaledValue(metric), attributes)
+	case "histogram", "distribution", "timing":
+		histogram, err := o.instrument(metric, metric.Type)
+		if err != nil {
+			return err
+		}
+		histogram.(otelmetric.Float64Histogram).Record(ctx, metric.Value, attributes)
+	case "set":
+		o.setWarning.Do(func() {
+			log.Printf("WARN: set metrics cannot be exported over OTLP, dropping %s", metric.Name)
+		})
+	default:
+		return fmt.Errorf("unknown metric type: %s", metric.Type)
+	}
+
+	return nil
+}
+
+// SendEvent drops the event, OpenTelemetry metrics have no events
+func (o *OTLP) SendEvent(event Event) error {
+	o.eventWarning.Do(func() {
+		log.Printf("WARN: events cannot be exported over OTLP, dropping events from %s", event.Monitor)
+	})
+
+	return nil
+}
+
+// Close exports anything still recorded and shuts the exporter down
+func (o *OTLP) Close() error {
+	return o.provider.Shutdown(context.Background())
+}
+
+// instrument returns the instrument for the metric, creating it on first use
+func (o *OTLP) instrument(m Metric, kind string) (interface{}, error) {
+	key := otlpInstrumentKey{scope: m.Monitor, name: m.Name, kind: kind}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if instrument, ok := o.instruments[key]; ok {
+		return instrument, nil
+	}
+
+	meter := o.provider.Meter(m.Monitor)
+
+	var instrument interface{}
+	var err error
+
+	switch kind {
+	case "gauge":
+		instrument, err = meter.Float64Gauge(m.Name)
+	case "count":
+		instrument, err = meter.Float64Counter(m.Name)
+	case "timing":
+		instrument, err = meter.Float64Histogram(m.Name, otelmetric.WithUnit("ms"))
+	default:
+		instrument, err = meter.Float64Histogram(m.Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	o.instruments[key] = instrument
+
+	return instrument, nil
+}
+
+func metricAttributes(tags []string) otelmetric.MeasurementOption {
+	return otelmetric.WithAttributes(tagsToAttributes(tags)...)
+}
+
+// tagsToAttributes splits key:value tags into attributes. Tags without a
+// value become attributes with an empty value.
+func tagsToAttributes(tags []string) []attribute.KeyValue {
+	attributes := make([]attribute.KeyValue, 0, len(tags))
+	for _, tag := range tags {
+		key, value, _ := strings.Cut(tag, ":")
+		attributes = append(attributes, attribute.String(key, value))
+	}
+
+	return attributes
+}
+
+func otlpGRPCOptions(otlpConfig config.OTLPConfig) []otlpmetricgrpc.Option {
+	options := []otlpmetricgrpc.Option{
+		otlpmetricgrpc.WithTimeout(otlpConfig.Timeout),
+		otlpmetricgrpc.WithCompressor("gzip"),
+	}
+
+	if strings.Contains(otlpConfig.Endpoint, "://") {
+		options = append(options, otlpmetricgrpc.WithEndpointURL(otlpConfig.Endpoint))
+	} else if otlpConfig.Endpoint != "" {
+		options = append(options, otlpmetricgrpc.WithEndpoint(otlpConfig.Endpoint))
+	}
+
+	if otlpConfig.Insecure {
+		options = append(options, otlpmetricgrpc.WithInsecure())
+	}
+
+	if len(otlpConfig.Headers) > 0 {
+		options = append(options, otlpmetricgrpc.WithHeaders(otlpConfig.Headers))
+	}
+
+	return options
+}
+
+func otlpHTTPOptions(otlpConfig config.OTLPConfig) []otlpmetrichttp.Option {
+	options := []otlpmetrichttp.Option{
+		otlpmetrichttp.WithTimeout(otlpConfig.Timeout),
+		otlpmetrichttp.WithCompression(otlpmetrichttp.GzipCompression),
+	}
+
+	if strings.Contains(otlpConfig.Endpoint, "://") {
+		options = append(options, otlpmetrichttp.WithEndpointURL(otlpConfig.Endpoint))
+	} else if otlpConfig.Endpoint != "" {
+		options = append(options, otlpmetrichttp.WithEndpoint(otlpConfig.Endpoint))
+	}
+
+	if otlpConfig.Insecure {
+		options = append(options, otlpmetrichttp.WithInsecure())
+	}
+
+	if len(otlpConfig.Headers) > 0 {
+		options = append(options, otlpmetrichttp.WithHeaders(otlpConfig.Headers))
+	}
+
+	return options
+}
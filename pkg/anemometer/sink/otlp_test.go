@@ -0,0 +1,123 @@
+package sink
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/simplifi/anemometer/pkg/anemometer/config"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func collect(t *testing.T, reader *sdkmetric.ManualReader) map[string]map[string]metricdata.Metrics {
+	var resourceMetrics metricdata.ResourceMetrics
+	assert.NoError(t, reader.Collect(context.Background(), &resourceMetrics))
+
+	// Metrics by scope, then by name
+	scopes := make(map[string]map[string]metricdata.Metrics)
+	for _, scopeMetrics := range resourceMetrics.ScopeMetrics {
+		metrics := make(map[string]metricdata.Metrics)
+		for _, m := range scopeMetrics.Metrics {
+			metrics[m.Name] = m
+		}
+		scopes[scopeMetrics.Scope.Name] = metrics
+	}
+
+	return scopes
+}
+
+func TestOTLPSendMetric(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	o := NewOTLPWithReader(reader, nil)
+	defer o.Close()
+
+	tags := []string{"table:users", "primary"}
+	attributes := attribute.NewSet(attribute.String("primary", ""), attribute.String("table", "users"))
+
+	assert.NoError(t, o.SendMetric(Metric{Monitor: "rows", Name: "db.rows", Type: "gauge", Value: 42, Tags: tags}))
+	assert.NoError(t, o.SendMetric(Metric{Monitor: "rows", Name: "db.rows", Type: "gauge", Value: 43, Tags: tags}))
+	assert.NoError(t, o.SendMetric(Metric{Monitor: "inserts", Name: "db.inserts", Type: "count", Value: 5, Tags: tags}))
+	assert.NoError(t, o.SendMetric(Metric{Monitor: "inserts", Name: "db.inserts", Type: "count", Value: 2, Tags: tags}))
+	assert.NoError(t, o.SendMetric(Metric{Monitor: "latency", Name: "db.latency", Type: "timing", Value: 12, Tags: tags}))
+	assert.NoError(t, o.SendMetric(Metric{Monitor: "users", Name: "db.users", Type: "set", SetValue: "alice"}))
+	assert.NoError(t, o.SendEvent(Event{Monitor: "rows", Title: "dropped"}))
+	assert.Error(t, o.SendMetric(Metric{Monitor: "inserts", Name: "db.inserts", Type: "count", Value: -1}))
+	assert.Error(t, o.SendMetric(Metric{Monitor: "rows", Name: "db.rows", Type: "summary"}))
+
+	scopes := collect(t, reader)
+	assert.Equal(t, 3, len(scopes))
+
+	gauge, ok := scopes["rows"]["db.rows"].Data.(metricdata.Gauge[float64])
+	assert.True(t, ok)
+	assert.Equal(t, 1, len(gauge.DataPoints))
+	assert.Equal(t, 43.0, gauge.DataPoints[0].Value)
+	assert.Equal(t, attributes, gauge.DataPoints[0].Attributes)
+
+	sum, ok := scopes["inserts"]["db.inserts"].Data.(metricdata.Sum[float64])
+	assert.True(t, ok)
+	assert.True(t, sum.IsMonotonic)
+	assert.Equal(t, 1, len(sum.DataPoints))
+	assert.Equal(t, 7.0, sum.DataPoints[0].Value)
+	assert.Equal(t, attributes, sum.DataPoints[0].Attributes)
+
+	latency := scopes["latency"]["db.latency"]
+	assert.Equal(t, "ms", latency.Unit)
+	histogram, ok := latency.Data.(metricdata.Histogram[float64])
+	assert.True(t, ok)
+	assert.Equal(t, 1, len(histogram.DataPoints))
+	assert.Equal(t, uint64(1), histogram.DataPoints[0].Count)
+	assert.Equal(t, 12.0, histogram.DataPoints[0].Sum)
+}
+
+func TestNewOTLPHTTP(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	o, err := NewOTLP(config.OTLPConfig{
+		Enabled:        true,
+		Protocol:       "http",
+		Endpoint:       server.URL,
+		Headers:        map[string]string{"Authorization": "Bearer token"},
+		ServiceName:    "anemometer",
+		Tags:           []string{"environment:test"},
+		ExportInterval: time.Hour,
+		Timeout:        5 * time.Second,
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, o.SendMetric(Metric{Monitor: "rows", Name: "db.rows", Type: "gauge", Value: 42}))
+	// Closing exports what has been recorded
+	assert.NoError(t, o.Close())
+
+	r := <-requests
+	assert.Equal(t, "/v1/metrics", r.URL.Path)
+	assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+	assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+}
+
+func TestNewOTLPGRPC(t *testing.T) {
+	// The gRPC exporter connects lazily, so no collector is needed to create it
+	o, err := NewOTLP(config.OTLPConfig{
+		Enabled:        true,
+		Protocol:       "grpc",
+		Endpoint:       "localhost:4317",
+		Insecure:       true,
+		ServiceName:    "anemometer",
+		ExportInterval: time.Hour,
+		Timeout:        time.Second,
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, o)
+
+	_, err = NewOTLP(config.OTLPConfig{Protocol: "thrift"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,187 @@
+package sink
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/simplifi/anemometer/pkg/anemometer/config"
+)
+
+// Metric is a single metric value produced by a monitor
+type Metric struct {
+	// Monitor is the name of the monitor that produced the metric
+	Monitor string
+	Name    string
+	// Type is one of gauge, count, histogram, distribution, set or timing
+	Type  string
+	Value float64
+	// SetValue holds the value of set metrics, which may be any string
+	SetValue string
+	Tags     []string
+	// Timestamp is zero for metrics that should be sent with the current time
+	Timestamp  time.Time
+	SampleRate float64
+}
+
+// Event is a single event produced by a monitor
+type Event struct {
+	// Monitor is the name of the monitor that produced the event
+	Monitor string
+	Title   string
+	Text    string
+	// AlertType is one of info, warning, error or success
+	AlertType string
+	// Priority is one of normal or low
+	Priority       string
+	SourceTypeName string
+	AggregationKey string
+	Hostname       string
+	Tags           []string
+	Timestamp      time.Time
+}
+
+// Sink is a destination for the metrics and events produced by monitors.
+// Sinks are shared by all monitors, so they must be safe for concurrent use.
+type Sink interface {
+	SendMetric(metric Metric) error
+	SendEvent(event Event) error
+	// Close flushes anything buffered and releases the sink's resources
+	Close() error
+}
+
+// New creates a Sink that sends to every destination enabled in the config
+func New(cfg *config.Config) (Sink, error) {
+	var sinks Multi
+
+	if cfg.StatsdConfig.Enabled {
+		statsdSink, err := NewStatsd(cfg.StatsdConfig)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, statsdSink)
+	}
+
+	if cfg.DatadogAPIConfig.Enabled {
+		datadogSink, err := NewDatadogAPI(cfg.DatadogAPIConfig)
+		if err != nil {
+			sinks.Close()
+			return nil, err
+		}
+		sinks = append(sinks, datadogSink)
+	}
+
+	if cfg.OTLPConfig.Enabled {
+		otlpSink, err := NewOTLP(cfg.OTLPConfig)
+		if err != nil {
+			sinks.Close()
+			return nil, err
+		}
+		sinks = append(sinks, otlpSink)
+	}
+
+	if cfg.InfluxDBConfig.Enabled {
+		influxSink, err := NewInfluxDB(cfg.InfluxDBConfig)
+		if err != nil {
+			sinks.Close()
+			return nil, err
+		}
+		sinks = append(sinks, influxSink)
+	}
+
+	if cfg.GraphiteConfig.Enabled {
+		graphiteSink, err := NewGraphite(cfg.GraphiteConfig)
+		if err != nil {
+			sinks.Close()
+			return nil, err
+		}
+		sinks = append(sinks, graphiteSink)
+	}
+
+	if cfg.JSONLinesConfig.Enabled {
+		jsonLinesSink, err := NewJSONLines(cfg.JSONLinesConfig)
+		if err != nil {
+			sinks.Close()
+			return nil, err
+		}
+		sinks = append(sinks, jsonLinesSink)
+	}
+
+	if cfg.WebhookConfig.Enabled {
+		webhookSink, err := NewWebhook(cfg.WebhookConfig)
+		if err != nil {
+			sinks.Close()
+			return nil, err
+		}
+		sinks = append(sinks, webhookSink)
+	}
+
+	if len(sinks) == 0 {
+		return nil, errors.New("no sinks are enabled")
+	}
+
+	if len(sinks) == 1 {
+		return sinks[0], nil
+	}
+
+	return sinks, nil
+}
+
+// Multi sends every metric and event to each of its sinks
+type Multi []Sink
+
+// SendMetric sends the metric to every sink, even if some of them fail
+func (m Multi) SendMetric(metric Metric) error {
+	var errs []error
+	for _, s := range m {
+		errs = append(errs, s.SendMetric(metric))
+	}
+
+	return errors.Join(errs...)
+}
+
+// SendEvent sends the event to every sink, even if some of them fail
+func (m Multi) SendEvent(event Event) error {
+	var errs []error
+	for _, s := range m {
+		errs = append(errs, s.SendEvent(event))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Close closes every sink
+func (m Multi) Close() error {
+	var errs []error
+	for _, s := range m {
+		errs = append(errs, s.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// sampled reports whether a metric should be sent, for sinks that sample
+// client side rather than passing the rate on
+func sampled(metric Metric) bool {
+	rate := sampleRate(metric)
+
+	return rate >= 1 || rand.Float64() <= rate
+}
+
+func sampleRate(metric Metric) float64 {
+	if metric.SampleRate <= 0 {
+		return 1
+	}
+
+	return metric.SampleRate
+}
+
+// scaledValue returns the metric's value, with sampled counts scaled back up
+// as the agent would, for sinks that don't pass the sample rate on
+func scaledValue(metric Metric) float64 {
+	if metric.Type == "count" {
+		return metric.Value / sampleRate(metric)
+	}
+
+	return metric.Value
+}
@@ -0,0 +1,179 @@
+package sink
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/simplifi/anemometer/pkg/anemometer/config"
+)
+
+const defaultStatsdPort = "8125"
+
+// Statsd sends metrics and events to DogStatsD
+type Statsd struct {
+	client statsd.ClientInterface
+}
+
+// NewStatsd creates a Statsd sink from the statsd config
+func NewStatsd(statsdConfig config.StatsdConfig) (*Statsd, error) {
+	client, err := createStatsdClient(statsdConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewStatsdWithClient(client), nil
+}
+
+// NewStatsdWithClient creates a Statsd sink that sends through an existing
+// client
+func NewStatsdWithClient(client statsd.ClientInterface) *Statsd {
+	return &Statsd{client: client}
+}
+
+// SendMetric sends the metric using the DogStatsD type matching its Type
+func (s *Statsd) SendMetric(metric Metric) error {
+	rate := sampleRate(metric)
+
+	switch metric.Type {
+	case "count":
+		if metric.Timestamp.IsZero() {
+			return s.client.Count(metric.Name, int64(metric.Value), metric.Tags, rate)
+		}
+		return s.client.CountWithTimestamp(metric.Name, int64(metric.Value), metric.Tags, rate, metric.Timestamp)
+	case "histogram":
+		return s.client.Histogram(metric.Name, metric.Value, metric.Tags, rate)
+	case "distribution":
+		return s.client.Distribution(metric.Name, metric.Value, metric.Tags, rate)
+	case "gauge":
+		if metric.Timestamp.IsZero() {
+			return s.client.Gauge(metric.Name, metric.Value, metric.Tags, rate)
+		}
+		return s.client.GaugeWithTimestamp(metric.Name, metric.Value, metric.Tags, rate, metric.Timestamp)
+	case "set":
+		return s.client.Set(metric.Name, metric.SetValue, metric.Tags, rate)
+	case "timing":
+		// The value is a duration in milliseconds
+		return s.client.TimeInMilliseconds(metric.Name, metric.Value, metric.Tags, rate)
+	default:
+		return fmt.Errorf("unknown metric type: %s", metric.Type)
+	}
+}
+
+// SendEvent sends the event to DogStatsD
+func (s *Statsd) SendEvent(event Event) error {
+	statsdEvent := statsd.NewEvent(event.Title, event.Text)
+	statsdEvent.AlertType = statsd.EventAlertType(event.AlertType)
+	statsdEvent.Priority = statsd.EventPriority(event.Priority)
+	statsdEvent.SourceTypeName = event.SourceTypeName
+	statsdEvent.AggregationKey = event.AggregationKey
+	statsdEvent.Hostname = event.Hostname
+	statsdEvent.Timestamp = event.Timestamp
+	statsdEvent.Tags = event.Tags
+
+	return s.client.Event(statsdEvent)
+}
+
+// Close flushes and closes the client
+func (s *Statsd) Close() error {
+	return s.client.Close()
+}
+
+func createStatsdClient(statsdConfig config.StatsdConfig) (statsd.ClientInterface, error) {
+	address, err := resolveStatsdAddress(statsdConfig.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := statsd.New(
+		address,
+		statsdOptions(statsdConfig)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// resolveStatsdAddress converts the configured address into the form the
+// statsd client expects. An empty address falls back to DD_DOGSTATSD_URL, then
+// DD_AGENT_HOST and DD_DOGSTATSD_PORT, like the Datadog tracers do.
+func resolveStatsdAddress(address string) (string, error) {
+	if address == "" {
+		address = os.Getenv("DD_DOGSTATSD_URL")
+	}
+
+	if address == "" {
+		host := os.Getenv("DD_AGENT_HOST")
+		if host == "" {
+			return "", fmt.Errorf("no statsd address configured and neither DD_DOGSTATSD_URL nor DD_AGENT_HOST is set")
+		}
+
+		port := os.Getenv("DD_DOGSTATSD_PORT")
+		if port == "" {
+			port = defaultStatsdPort
+		}
+
+		return net.JoinHostPort(host, port), nil
+	}
+
+	scheme, rest, found := strings.Cut(address, "://")
+	if !found {
+		// A plain host:port is UDP
+		return address, nil
+	}
+
+	switch scheme {
+	case "udp":
+		if _, _, err := net.SplitHostPort(rest); err != nil {
+			return net.JoinHostPort(rest, defaultStatsdPort), nil
+		}
+		return rest, nil
+	case "unix", "unixgram", "unixstream":
+		// Unix domain sockets (datagram, stream, or detected) are handled by
+		// the client itself
+		return address, nil
+	default:
+		return "", fmt.Errorf("unsupported statsd address scheme: %s", scheme)
+	}
+}
+
+// statsdOptions converts the statsd config into client options, leaving the
+// client's defaults in place for anything that isn't set
+func statsdOptions(statsdConfig config.StatsdConfig) []statsd.Option {
+	options := []statsd.Option{
+		statsd.WithTags(statsdConfig.Tags),
+	}
+
+	if statsdConfig.Namespace != "" {
+		options = append(options, statsd.WithNamespace(statsdConfig.Namespace))
+	}
+
+	switch statsdConfig.Aggregation {
+	case "extended":
+		options = append(options, statsd.WithExtendedClientSideAggregation())
+	case "none":
+		options = append(options, statsd.WithoutClientSideAggregation())
+	}
+
+	if statsdConfig.AggregationInterval > 0 {
+		options = append(options, statsd.WithAggregationInterval(statsdConfig.AggregationInterval))
+	}
+	if statsdConfig.BufferPoolSize > 0 {
+		options = append(options, statsd.WithBufferPoolSize(statsdConfig.BufferPoolSize))
+	}
+	if statsdConfig.MaxMessagesPerPayload > 0 {
+		options = append(options, statsd.WithMaxMessagesPerPayload(statsdConfig.MaxMessagesPerPayload))
+	}
+	if statsdConfig.FlushInterval > 0 {
+		options = append(options, statsd.WithBufferFlushInterval(statsdConfig.FlushInterval))
+	}
+	if statsdConfig.DisableTelemetry {
+		options = append(options, statsd.WithoutTelemetry())
+	}
+
+	return options
+}
@@ -0,0 +1,125 @@
+package sink
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/simplifi/anemometer/pkg/anemometer/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateStatsdClientOptions(t *testing.T) {
+	statsdConfig := config.StatsdConfig{
+		Address:               "localhost:8125",
+		Tags:                  []string{"environment:test"},
+		Namespace:             "anemometer.",
+		Aggregation:           "extended",
+		AggregationInterval:   5 * time.Second,
+		BufferPoolSize:        64,
+		MaxMessagesPerPayload: 32,
+		FlushInterval:         250 * time.Millisecond,
+		DisableTelemetry:      true,
+	}
+
+	// Tags plus one option for each setting
+	assert.Equal(t, 8, len(statsdOptions(statsdConfig)))
+	// Unset options leave the client's defaults alone
+	assert.Equal(t, 1, len(statsdOptions(config.StatsdConfig{Aggregation: "basic"})))
+
+	client, err := createStatsdClient(statsdConfig)
+	assert.NoError(t, err)
+	assert.NoError(t, client.Close())
+}
+
+func TestResolveStatsdAddress(t *testing.T) {
+	tests := []struct {
+		name        string
+		address     string
+		env         map[string]string
+		expected    string
+		expectedErr string
+	}{
+		{name: "host_port", address: "127.0.0.1:8125", expected: "127.0.0.1:8125"},
+		{name: "udp_scheme", address: "udp://datadog-agent:8125", expected: "datadog-agent:8125"},
+		{name: "udp_scheme_default_port", address: "udp://datadog-agent", expected: "datadog-agent:8125"},
+		{name: "unix_socket", address: "unix:///var/run/datadog/dsd.socket", expected: "unix:///var/run/datadog/dsd.socket"},
+		{name: "unixgram_socket", address: "unixgram:///var/run/datadog/dsd.socket", expected: "unixgram:///var/run/datadog/dsd.socket"},
+		{name: "unixstream_socket", address: "unixstream:///var/run/datadog/dsd.socket", expected: "unixstream:///var/run/datadog/dsd.socket"},
+		{name: "unsupported_scheme", address: "tcp://datadog-agent:8125", expectedErr: "unsupported statsd address scheme: tcp"},
+		{
+			name:     "dogstatsd_url_env",
+			env:      map[string]string{"DD_DOGSTATSD_URL": "unix:///var/run/datadog/dsd.socket", "DD_AGENT_HOST": "ignored"},
+			expected: "unix:///var/run/datadog/dsd.socket",
+		},
+		{
+			name:     "dogstatsd_url_env_udp",
+			env:      map[string]string{"DD_DOGSTATSD_URL": "udp://10.0.0.5:8125"},
+			expected: "10.0.0.5:8125",
+		},
+		{
+			name:     "agent_host_env",
+			env:      map[string]string{"DD_AGENT_HOST": "10.0.0.5"},
+			expected: "10.0.0.5:8125",
+		},
+		{
+			name:     "agent_host_and_port_env",
+			env:      map[string]string{"DD_AGENT_HOST": "10.0.0.5", "DD_DOGSTATSD_PORT": "18125"},
+			expected: "10.0.0.5:18125",
+		},
+		{
+			name:     "address_wins_over_env",
+			address:  "127.0.0.1:8125",
+			env:      map[string]string{"DD_DOGSTATSD_URL": "unix:///var/run/datadog/dsd.socket"},
+			expected: "127.0.0.1:8125",
+		},
+		{name: "nothing_configured", expectedErr: "no statsd address configured"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"DD_DOGSTATSD_URL", "DD_AGENT_HOST", "DD_DOGSTATSD_PORT"} {
+				t.Setenv(name, tt.env[name])
+			}
+
+			address, err := resolveStatsdAddress(tt.address)
+			if tt.expectedErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, address)
+		})
+	}
+}
+
+func TestCreateStatsdClientUnixSocket(t *testing.T) {
+	dir, err := os.MkdirTemp("", "dsd")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	socketPath := filepath.Join(dir, "dsd.socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	client, err := createStatsdClient(config.StatsdConfig{
+		Address:          "unixgram://" + socketPath,
+		Aggregation:      "none",
+		DisableTelemetry: true,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, client.Gauge("test.metric", 42, []string{"environment:test"}, 1))
+	assert.NoError(t, client.Close())
+
+	buffer := make([]byte, 1024)
+	assert.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, err := conn.Read(buffer)
+	assert.NoError(t, err)
+	assert.Equal(t, "test.metric:42|g|#environment:test", strings.TrimSpace(string(buffer[:n])))
+}
@@ -0,0 +1,79 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/simplifi/anemometer/pkg/anemometer/config"
+)
+
+// Webhook posts events to an HTTP endpoint, rendering each event into a JSON
+// body with the configured template. Metrics are not sent.
+type Webhook struct {
+	config   config.WebhookConfig
+	template *template.Template
+	poster   *httpPoster
+}
+
+// NewWebhook creates a Webhook sink from an already normalized config
+func NewWebhook(webhookConfig config.WebhookConfig) (*Webhook, error) {
+	bodyTemplate, err := template.New("webhook").Funcs(config.TemplateFuncs).Parse(webhookConfig.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := map[string]string{"Content-Type": "application/json"}
+	for key, value := range webhookConfig.Headers {
+		headers[key] = value
+	}
+
+	return &Webhook{
+		config:   webhookConfig,
+		template: bodyTemplate,
+		poster:   newHTTPPoster(webhookConfig.Timeout, headers, retryCount(webhookConfig.MaxRetries), webhookConfig.RetryBackoff),
+	}, nil
+}
+
+// SendMetric does nothing, webhooks only receive events
+func (w *Webhook) SendMetric(metric Metric) error {
+	return nil
+}
+
+// SendEvent renders the event and posts it to the webhook
+func (w *Webhook) SendEvent(event Event) error {
+	body, err := w.render(event)
+	if err != nil {
+		return err
+	}
+
+	return w.poster.post(w.config.URL, body)
+}
+
+// Close does nothing, events are sent as they arrive
+func (w *Webhook) Close() error {
+	return nil
+}
+
+// render executes the template for the event, checking the result is JSON
+func (w *Webhook) render(event Event) ([]byte, error) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	if event.Tags == nil {
+		event.Tags = []string{}
+	}
+
+	var body bytes.Buffer
+	if err := w.template.Execute(&body, event); err != nil {
+		return nil, err
+	}
+
+	if !json.Valid(body.Bytes()) {
+		return nil, fmt.Errorf("webhook template did not render valid JSON: %s", body.String())
+	}
+
+	return body.Bytes(), nil
+}
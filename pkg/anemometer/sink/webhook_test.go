@@ -0,0 +1,121 @@
+package sink
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/simplifi/anemometer/pkg/anemometer/config"
+	"github.com/stretchr/testify/assert"
+)
+
+var testEvent = Event{
+	Monitor:   "long-running-queries",
+	Title:     `Query "41273" running for 2h`,
+	Text:      "SELECT *\nFROM events",
+	AlertType: "warning",
+	Priority:  "normal",
+	Tags:      []string{"database:analytics"},
+	Timestamp: time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC),
+}
+
+func newTestWebhook(t *testing.T, url string, bodyTemplate string) *Webhook {
+	maxRetries := 2
+	w, err := NewWebhook(config.WebhookConfig{
+		Enabled:      true,
+		URL:          url,
+		Template:     bodyTemplate,
+		Headers:      map[string]string{"Authorization": "Bearer token"},
+		MaxRetries:   &maxRetries,
+		RetryBackoff: time.Millisecond,
+		Timeout:      5 * time.Second,
+	})
+	assert.NoError(t, err)
+
+	return w
+}
+
+func TestWebhookSendEvent(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		expected map[string]interface{}
+	}{
+		{
+			name:     "default",
+			template: config.DefaultWebhookTemplate,
+			expected: map[string]interface{}{
+				"monitor":         "long-running-queries",
+				"title":           `Query "41273" running for 2h`,
+				"text":            "SELECT *\nFROM events",
+				"alert_type":      "warning",
+				"priority":        "normal",
+				"aggregation_key": "",
+				"hostname":        "",
+				"tags":            []interface{}{"database:analytics"},
+				"timestamp":       "2023-11-14T22:13:20Z",
+			},
+		},
+		{
+			name:     "slack",
+			template: `{"text": {{json (printf "*%s*\n%s" .Title .Text)}}}`,
+			expected: map[string]interface{}{
+				"text": "*Query \"41273\" running for 2h*\nSELECT *\nFROM events",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bodies := make(chan map[string]interface{}, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+				var body map[string]interface{}
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+				bodies <- body
+			}))
+			defer server.Close()
+
+			webhook := newTestWebhook(t, server.URL, tt.template)
+			assert.NoError(t, webhook.SendMetric(Metric{Name: "db.rows", Type: "gauge"}))
+			assert.NoError(t, webhook.SendEvent(testEvent))
+			assert.NoError(t, webhook.Close())
+
+			assert.Equal(t, tt.expected, <-bodies)
+		})
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		io.Copy(io.Discard, r.Body)
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	webhook := newTestWebhook(t, server.URL, config.DefaultWebhookTemplate)
+	assert.NoError(t, webhook.SendEvent(testEvent))
+	assert.Equal(t, 3, attempts)
+}
+
+func TestWebhookInvalidJSON(t *testing.T) {
+	webhook := newTestWebhook(t, "http://127.0.0.1:1", `{"text": "{{.Title}}"}`)
+
+	err := webhook.SendEvent(testEvent)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "webhook template did not render valid JSON")
+}